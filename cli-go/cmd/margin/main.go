@@ -3,22 +3,30 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/spf13/cobra"
 
 	"margin/internal/config"
+	"margin/internal/doctor"
+	"margin/internal/inbox"
 	"margin/internal/mcpserver"
+	"margin/internal/notecat"
 	"margin/internal/remind"
 	"margin/internal/rootio"
 	"margin/internal/runblock"
 	"margin/internal/search"
 	"margin/internal/slackcap"
+	"margin/internal/snapshot"
+	"margin/internal/updatecheck"
 )
 
 var (
@@ -27,6 +35,14 @@ var (
 	date    = "unknown"
 )
 
+var configStrict = true
+
+const (
+	rootLockTimeout       = 5 * time.Second
+	scheduleGuardLock     = "schedule.lock"
+	scheduleGuardExitCode = 3
+)
+
 type cliError struct {
 	code int
 	msg  string
@@ -69,39 +85,89 @@ func newRootCmd() *cobra.Command {
 	root := &cobra.Command{
 		Use:  "margin",
 		Args: cobra.NoArgs,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return resolveRootFlag(cmd)
+		},
 		RunE: func(cmd *cobra.Command, args []string) error {
 			writeVersionJSON()
 			return nil
 		},
 	}
 
+	root.PersistentFlags().BoolVar(&configStrict, "config-strict", true, "fail on malformed config instead of falling back to defaults")
+
 	root.AddCommand(newVersionCmd())
 	root.AddCommand(newSearchCmd())
 	root.AddCommand(newRemindCmd())
 	root.AddCommand(newRunBlockCmd())
 	root.AddCommand(newSlackCmd())
 	root.AddCommand(newMCPCmd())
+	root.AddCommand(newReindexCmd())
+	root.AddCommand(newConfigCmd())
+	root.AddCommand(newInboxCmd())
+	root.AddCommand(newCatCmd())
+	root.AddCommand(newImportMDCmd())
+	root.AddCommand(newDoctorCmd())
+	root.AddCommand(newDaemonCmd())
 	return root
 }
 
 func newVersionCmd() *cobra.Command {
-	return &cobra.Command{
+	var check bool
+	var checkURL string
+
+	cmd := &cobra.Command{
 		Use:   "version",
 		Short: "Print build metadata",
 		Args:  cobra.NoArgs,
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if check {
+				writeJSON(updatecheck.Check(cmd.Context(), checkURL, version))
+				return nil
+			}
 			writeVersionJSON()
 			return nil
 		},
 	}
+	cmd.Flags().BoolVar(&check, "check", false, "compare against the latest published release")
+	cmd.Flags().StringVar(&checkURL, "check-url", updatecheck.DefaultURL, "release API endpoint returning tag_name")
+	return cmd
 }
 
 func newSearchCmd() *cobra.Command {
-	var query string
+	var queries []string
+	var matchAll bool
+	var matchAny bool
 	var paths string
 	var limit int
 	var root string
 	var configPath string
+	var hidden bool
+	var withStats bool
+	var includeBinary bool
+	var excludeHistory bool
+	var previewWindow int
+	var previewTrim string
+	var contextLines int
+	var mergeGap int
+	var previewLines int
+	var groupBy string
+	var modifiedAfter string
+	var modifiedBefore string
+	var pathStyle string
+	var replacement string
+	var diff bool
+	var format string
+	var pathsOnly bool
+	var anchors bool
+	var envelope string
+	var scope string
+	var invert bool
+	var acrossLines bool
+	var filesWithMatches bool
+	var encodingName string
+	var pathsFromFile string
+	var filesFrom string
 
 	cmd := &cobra.Command{
 		Use:   "search",
@@ -113,30 +179,246 @@ func newSearchCmd() *cobra.Command {
 				return err
 			}
 			groups := cfg.SearchPaths
-			if strings.TrimSpace(paths) != "" {
+			if strings.TrimSpace(paths) != "" || pathsFromFile != "" {
 				groups = splitCSV(paths)
 			}
-			res, err := search.Run(cmd.Context(), root, query, groups, limit)
+			if pathsFromFile != "" {
+				extra, err := rootio.ReadPathList(pathsFromFile)
+				if err != nil {
+					return cliError{code: 2, msg: fmt.Sprintf("invalid --paths-from-file: %v", err)}
+				}
+				groups = append(groups, extra...)
+			}
+			if !search.ValidPathStyle(pathStyle) {
+				return cliError{code: 2, msg: fmt.Sprintf("invalid --paths-relative-to: %s", pathStyle)}
+			}
+			query := ""
+			if len(queries) > 0 {
+				query = queries[0]
+			}
+			combine := ""
+			switch {
+			case matchAll && matchAny:
+				return cliError{code: 2, msg: "--and and --or are mutually exclusive"}
+			case matchAll:
+				combine = search.CombineAnd
+			case matchAny:
+				combine = search.CombineOr
+			case len(queries) > 1:
+				return cliError{code: 2, msg: "multiple --query values require --and or --or"}
+			}
+			if combine == search.CombineOr && acrossLines {
+				return cliError{code: 2, msg: "--or cannot be combined with --across-lines"}
+			}
+			if invert && acrossLines {
+				return cliError{code: 2, msg: "--invert cannot be combined with --across-lines"}
+			}
+			if !search.ValidEncoding(encodingName) {
+				return cliError{code: 2, msg: fmt.Sprintf("invalid --encoding: %s", encodingName)}
+			}
+			if !search.ValidScope(scope) {
+				return cliError{code: 2, msg: fmt.Sprintf("invalid --scope: %s", scope)}
+			}
+			if !search.ValidFormat(format) {
+				return cliError{code: 2, msg: fmt.Sprintf("invalid --format: %s", format)}
+			}
+			if format == search.FormatGrep && withStats {
+				return cliError{code: 2, msg: "--stats is not supported with --format grep"}
+			}
+			if !search.ValidEnvelope(envelope) {
+				return cliError{code: 2, msg: fmt.Sprintf("invalid --envelope: %s", envelope)}
+			}
+			if format == search.FormatGrep && envelope == search.EnvelopeObject {
+				return cliError{code: 2, msg: "--envelope object is not supported with --format grep"}
+			}
+			if pathsOnly && (withStats || envelope == search.EnvelopeObject) {
+				return cliError{code: 2, msg: "--output-paths-only cannot be combined with --stats or --envelope object"}
+			}
+			if !search.ValidGroupBy(groupBy) {
+				return cliError{code: 2, msg: fmt.Sprintf("invalid --group-by: %s", groupBy)}
+			}
+			if groupBy != "" && (format == search.FormatGrep || pathsOnly || envelope == search.EnvelopeObject) {
+				return cliError{code: 2, msg: "--group-by cannot be combined with --format grep, --output-paths-only, or --envelope object"}
+			}
+			if !search.ValidPreviewTrim(previewTrim) {
+				return cliError{code: 2, msg: fmt.Sprintf("invalid --preview-trim: %s", previewTrim)}
+			}
+			if limit < 0 {
+				return cliError{code: 2, msg: fmt.Sprintf("invalid --limit: %d", limit)}
+			}
+			if previewLines < 0 {
+				return cliError{code: 2, msg: fmt.Sprintf("invalid --preview-lines: %d", previewLines)}
+			}
+			if previewLines > 1 && format == search.FormatGrep {
+				return cliError{code: 2, msg: "--preview-lines is not supported with --format grep"}
+			}
+			if mergeGap < 0 {
+				return cliError{code: 2, msg: fmt.Sprintf("invalid --merge-adjacent: %d", mergeGap)}
+			}
+			limit = search.EffectiveLimit(limit, cfg.Search.MaxResults)
+			opts := search.Options{
+				Hidden:           hidden,
+				IncludeBinary:    includeBinary,
+				PreviewWindow:    previewWindow,
+				PreviewTrim:      previewTrim,
+				ContextLines:     contextLines,
+				MergeAdjacent:    mergeGap,
+				PreviewLines:     previewLines,
+				PathStyle:        pathStyle,
+				Scope:            scope,
+				Invert:           invert,
+				AcrossLines:      acrossLines,
+				FilesWithMatches: filesWithMatches,
+				MinQueryLength:   cfg.Search.MinQueryLength,
+				MaxResults:       cfg.Search.MaxResults,
+				Encoding:         encodingName,
+				ExcludeHistory:   cfg.Search.ExcludeHistory,
+				Anchors:          anchors,
+			}
+			if cmd.Flags().Changed("exclude-history") {
+				opts.ExcludeHistory = excludeHistory
+			}
+			if filesFrom != "" {
+				if cmd.Flags().Changed("paths") || pathsFromFile != "" {
+					return cliError{code: 2, msg: "--files-from cannot be combined with --paths or --paths-from-file"}
+				}
+				rels, err := rootio.ReadPathList(filesFrom)
+				if err != nil {
+					return cliError{code: 2, msg: fmt.Sprintf("invalid --files-from: %v", err)}
+				}
+				if opts.Files, err = search.ResolveFileList(root, rels); err != nil {
+					return cliError{code: 2, msg: fmt.Sprintf("invalid --files-from: %v", err)}
+				}
+			}
+			if len(queries) > 1 {
+				opts.Patterns = queries[1:]
+				opts.Combine = combine
+			}
+			if modifiedAfter != "" {
+				t, err := search.ParseTimeBound(modifiedAfter, time.Now())
+				if err != nil {
+					return cliError{code: 2, msg: fmt.Sprintf("invalid --modified-after: %v", err)}
+				}
+				opts.ModifiedAfter = t
+			}
+			if modifiedBefore != "" {
+				t, err := search.ParseTimeBound(modifiedBefore, time.Now())
+				if err != nil {
+					return cliError{code: 2, msg: fmt.Sprintf("invalid --modified-before: %v", err)}
+				}
+				opts.ModifiedBefore = t
+			}
+			if cmd.Flags().Changed("replace") {
+				if len(queries) > 1 {
+					return cliError{code: 2, msg: "--replace accepts a single --query"}
+				}
+				if cmd.Flags().Changed("encoding") {
+					return cliError{code: 2, msg: "--replace does not support --encoding"}
+				}
+				if invert {
+					return cliError{code: 2, msg: "--replace does not support --invert"}
+				}
+				if !diff {
+					return cliError{code: 2, msg: "--replace only supports a --diff preview"}
+				}
+				return runReplace(cmd, root, query, replacement, groups, opts)
+			}
+			if diff {
+				return cliError{code: 2, msg: "--diff requires --replace"}
+			}
+			res, stats, err := search.RunWithStats(cmd.Context(), root, query, groups, limit, opts)
 			if err != nil {
 				return cliError{code: 1, msg: fmt.Sprintf("search: %v", err)}
 			}
-			writeJSON(res)
+			if pathsOnly {
+				_ = search.WritePaths(os.Stdout, res)
+				return nil
+			}
+			if envelope == search.EnvelopeObject {
+				env := search.NewEnvelope(queries, res, limit)
+				if withStats {
+					env.Stats = &stats
+				}
+				writeJSON(env)
+				return nil
+			}
+			var out any = res
+			if groupBy == search.GroupByFile {
+				out = search.GroupResultsByFile(res)
+			}
+			if withStats {
+				writeJSON(map[string]any{"results": out, "stats": stats})
+				return nil
+			}
+			if format == search.FormatGrep {
+				_ = search.WriteGrep(os.Stdout, res)
+				return nil
+			}
+			writeJSON(out)
 			return nil
 		},
 	}
-	cmd.Flags().StringVar(&query, "query", "", "query")
+	cmd.Flags().StringArrayVar(&queries, "query", nil, "query (repeatable with --and/--or)")
+	cmd.Flags().BoolVar(&matchAll, "and", false, "require every --query on the same line (or file with --across-lines)")
+	cmd.Flags().BoolVar(&matchAny, "or", false, "match lines containing any --query")
 	cmd.Flags().StringVar(&paths, "paths", "", "comma paths")
-	cmd.Flags().IntVar(&limit, "limit", 50, "limit")
+	cmd.Flags().StringVar(&pathsFromFile, "paths-from-file", "", "file with one path group per line, merged with --paths")
+	cmd.Flags().StringVar(&filesFrom, "files-from", "", "file listing root-relative note paths; search or preview replacements only in those files")
+	cmd.Flags().IntVar(&limit, "limit", 50, "maximum results (0 = unlimited, capped by search.max_results)")
+	cmd.Flags().BoolVar(&hidden, "hidden", false, "include dot-prefixed files and directories")
+	cmd.Flags().BoolVar(&excludeHistory, "exclude-history", false, "skip scratch/history snapshots (default from search.exclude_history)")
+	cmd.Flags().StringVar(&encodingName, "encoding", "utf-8", "decode files from this encoding (e.g. windows-1252, shift_jis)")
+	cmd.Flags().BoolVar(&acrossLines, "across-lines", false, "match files containing all query terms anywhere; one result per file")
+	cmd.Flags().BoolVar(&invert, "invert", false, "return lines that do not match the query")
+	cmd.Flags().BoolVar(&filesWithMatches, "files-with-matches", false, "return only the first hit in each file; --limit counts files")
+	cmd.Flags().StringVar(&scope, "scope", search.ScopeAll, "headings|code|prose|all (markdown structure)")
+	cmd.Flags().StringVar(&format, "format", search.FormatJSON, "json|grep")
+	cmd.Flags().BoolVar(&anchors, "anchor", false, "add a short content hash of each matched line as anchor")
+	cmd.Flags().BoolVar(&pathsOnly, "output-paths-only", false, "print each matching file path once, one per line")
+	cmd.Flags().StringVar(&envelope, "envelope", search.EnvelopeArray, "array|object (object adds a meta block)")
+	cmd.Flags().BoolVar(&withStats, "stats", false, "wrap output with timing and backend stats")
+	cmd.Flags().BoolVar(&includeBinary, "include-binary", false, "search files that look binary")
+	cmd.Flags().IntVar(&previewWindow, "preview-window", 0, "center previews on the match with N chars of context (0 = whole line)")
+	cmd.Flags().StringVar(&previewTrim, "preview-trim", search.PreviewTrim, "trim|left-strip|none")
+	cmd.Flags().IntVar(&contextLines, "context-lines", 0, "include N lines before and after each match")
+	cmd.Flags().IntVar(&previewLines, "preview-lines", 0, "extend each preview to N lines starting at the match, joined with newlines")
+	cmd.Flags().StringVar(&groupBy, "group-by", "", "file (nest matches under each file; default flat list)")
+	cmd.Flags().IntVar(&mergeGap, "merge-adjacent", 0, "merge matches in the same file within N lines into one result")
+	cmd.Flags().StringVar(&modifiedAfter, "modified-after", "", "only files modified after RFC3339, YYYY-MM-DD, or relative (7d)")
+	cmd.Flags().StringVar(&pathStyle, "paths-relative-to", search.PathsRelativeToRoot, "root|cwd|abs")
+	cmd.Flags().StringVar(&modifiedBefore, "modified-before", "", "only files modified before RFC3339, YYYY-MM-DD, or relative (7d)")
+	cmd.Flags().StringVar(&replacement, "replace", "", "proposed replacement text for query matches (requires --diff)")
+	cmd.Flags().BoolVar(&diff, "diff", false, "print a unified diff of --replace; files are not modified")
 	cmd.Flags().StringVar(&root, "root", rootio.DefaultRoot(), "root")
 	cmd.Flags().StringVar(&configPath, "config", "", "config path")
 	return cmd
 }
 
+func runReplace(cmd *cobra.Command, root, query, replacement string, groups []string, opts search.Options) error {
+	edits, err := search.PlanReplace(cmd.Context(), root, query, replacement, groups, opts)
+	if err != nil {
+		return cliError{code: 1, msg: fmt.Sprintf("search replace: %v", err)}
+	}
+	for _, e := range edits {
+		_, _ = fmt.Fprint(os.Stdout, e.UnifiedDiff())
+	}
+	return nil
+}
+
 func newRemindCmd() *cobra.Command {
 	var root string
 	var configPath string
 	var includeHistory bool
+	var hidden bool
 	var notify bool
+	var catchUp string
+	var dryRun bool
+	var jsonOnly bool
+	var runGuard bool
+	var preview bool
+	var watchInterval time.Duration
+	var watchSchedule bool
+	var sourceFilter string
 
 	remindCmd := &cobra.Command{
 		Use:   "remind",
@@ -150,10 +432,42 @@ func newRemindCmd() *cobra.Command {
 		Short: "Scan notes for reminders",
 		Args:  cobra.NoArgs,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			if _, err := loadConfigAndLayout(root, configPath); err != nil {
+			if watchInterval < 0 {
+				return cliError{code: 2, msg: "--watch-interval must be positive"}
+			}
+			if watchSchedule && watchInterval == 0 {
+				return cliError{code: 2, msg: "--schedule requires --watch-interval"}
+			}
+			if watchSchedule && dryRun {
+				return cliError{code: 2, msg: "--schedule cannot be combined with --dry-run"}
+			}
+			if err := remind.ValidSourceFilter(sourceFilter); err != nil {
+				return cliError{code: 2, msg: fmt.Sprintf("invalid --source-filter: %v", err)}
+			}
+			cfg, err := loadConfigAndLayout(root, configPath)
+			if err != nil {
 				return err
 			}
-			res, err := remind.Scan(cmd.Context(), root, includeHistory)
+			scanOpts := remind.ScanOptions{
+				IncludeHistory:  includeHistory,
+				Hidden:          hidden,
+				DedupeByMessage: cfg.Remind.DedupeByMessage,
+				DefaultMessage:  cfg.Remind.DefaultMessage,
+				DryRun:          dryRun,
+				Preview:         preview,
+				SourceFilter:    filepath.ToSlash(sourceFilter),
+			}
+			if watchInterval > 0 {
+				return watchRemindScan(cmd.Context(), root, watchInterval, scanOpts, watchSchedule, cfg.Remind)
+			}
+			if !dryRun {
+				unlock, err := lockRoot(root)
+				if err != nil {
+					return err
+				}
+				defer unlock()
+			}
+			res, err := remind.Scan(cmd.Context(), root, scanOpts)
 			if err != nil {
 				return cliError{code: 1, msg: fmt.Sprintf("remind scan: %v", err)}
 			}
@@ -162,16 +476,44 @@ func newRemindCmd() *cobra.Command {
 		},
 	}
 	scanCmd.Flags().BoolVar(&includeHistory, "include-history", false, "include scratch history")
+	scanCmd.Flags().BoolVar(&hidden, "hidden", false, "include dot-prefixed files and directories")
+	scanCmd.Flags().BoolVar(&preview, "preview", false, "include the source line and its neighbours for each added reminder")
+	scanCmd.Flags().BoolVar(&dryRun, "dry-run", false, "report reminders that would be added without saving")
+	scanCmd.Flags().StringVar(&sourceFilter, "source-filter", "", "only scan files whose root-relative path (or a parent directory) matches this glob")
+	scanCmd.Flags().DurationVar(&watchInterval, "watch-interval", 0, "rescan on this interval until interrupted (e.g. 60s)")
+	scanCmd.Flags().BoolVar(&watchSchedule, "schedule", false, "run the scheduler after each scan (with --watch-interval)")
 
 	scheduleCmd := &cobra.Command{
 		Use:   "schedule",
 		Short: "Run reminder scheduler",
 		Args:  cobra.NoArgs,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			if _, err := loadConfigAndLayout(root, configPath); err != nil {
+			if !remind.ValidCatchUp(catchUp) {
+				return cliError{code: 2, msg: fmt.Sprintf("invalid --catch-up: %s", catchUp)}
+			}
+			cfg, err := loadConfigAndLayout(root, configPath)
+			if err != nil {
+				return err
+			}
+			if jsonOnly && cmd.Flags().Changed("notify") && notify {
+				return cliError{code: 2, msg: "--json-only cannot be combined with --notify=true"}
+			}
+			if runGuard {
+				guard, err := rootio.LockNamed(root, scheduleGuardLock, 0)
+				if errors.Is(err, rootio.ErrLocked) {
+					return cliError{code: scheduleGuardExitCode, msg: "remind schedule: another schedule run is in progress"}
+				}
+				if err != nil {
+					return cliError{code: 1, msg: fmt.Sprintf("remind schedule: %v", err)}
+				}
+				defer func() { _ = guard.Release() }()
+			}
+			unlock, err := lockRoot(root)
+			if err != nil {
 				return err
 			}
-			res, err := remind.Schedule(cmd.Context(), root, notify)
+			defer unlock()
+			res, err := remind.Schedule(cmd.Context(), root, remind.ScheduleOptions{Notify: notify && !jsonOnly, CatchUp: catchUp, Config: cfg.Remind})
 			if err != nil {
 				return cliError{code: 1, msg: fmt.Sprintf("remind schedule: %v", err)}
 			}
@@ -180,14 +522,492 @@ func newRemindCmd() *cobra.Command {
 		},
 	}
 	scheduleCmd.Flags().BoolVar(&notify, "notify", true, "attempt desktop notifications")
+	scheduleCmd.Flags().BoolVar(&jsonOnly, "json-only", false, "print due reminders without running any notifier")
+	scheduleCmd.Flags().BoolVar(&runGuard, "once-per-run-guard", false, "exit with code 3 if another schedule run is in progress")
+	scheduleCmd.Flags().StringVar(&catchUp, "catch-up", remind.CatchUpFireAll, "fire-all|fire-latest|mark-silent")
+
+	var digestNotify bool
+	digestCmd := &cobra.Command{
+		Use:   "digest",
+		Short: "Summarize overdue reminders without firing them",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfigAndLayout(root, configPath)
+			if err != nil {
+				return err
+			}
+			res, err := remind.Digest(cmd.Context(), root, remind.DigestOptions{Notify: digestNotify, Config: cfg.Remind})
+			if err != nil {
+				return cliError{code: 1, msg: fmt.Sprintf("remind digest: %v", err)}
+			}
+			writeJSON(res)
+			return nil
+		},
+	}
+	digestCmd.Flags().BoolVar(&digestNotify, "notify", false, "send the digest as a single notification")
+
+	var nextOverdue bool
+	nextCmd := &cobra.Command{
+		Use:   "next",
+		Short: "Print the next pending reminder",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if _, err := loadConfigAndLayout(root, configPath); err != nil {
+				return err
+			}
+			next, err := remind.Next(cmd.Context(), root, nextOverdue)
+			if err != nil {
+				return cliError{code: 1, msg: fmt.Sprintf("remind next: %v", err)}
+			}
+			if next == nil {
+				writeJSON(struct{}{})
+				return nil
+			}
+			writeJSON(next)
+			return nil
+		},
+	}
+	nextCmd.Flags().BoolVar(&nextOverdue, "overdue", false, "return the most overdue pending reminder instead")
+
+	var listAll bool
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List stored reminders",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if _, err := loadConfigAndLayout(root, configPath); err != nil {
+				return err
+			}
+			load := remind.LoadEntries
+			if listAll {
+				load = remind.LoadAllEntries
+			}
+			entries, err := load(root)
+			if err != nil {
+				return cliError{code: 1, msg: fmt.Sprintf("remind list: %v", err)}
+			}
+			if entries == nil {
+				entries = []remind.Entry{}
+			}
+			writeJSON(map[string]any{"entries": entries})
+			return nil
+		},
+	}
+	listCmd.Flags().BoolVar(&listAll, "all", false, "include archived fired reminders")
 
-	remindCmd.AddCommand(scanCmd, scheduleCmd)
+	var testMessage string
+	var testNotifier string
+	testNotifyCmd := &cobra.Command{
+		Use:   "test-notify",
+		Short: "Send one test notification",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig(root, configPath)
+			if err != nil {
+				return err
+			}
+			res := remind.TestNotify(cmd.Context(), cfg.Remind, testNotifier, testMessage)
+			writeJSON(res)
+			if !res.OK {
+				return cliError{code: 1, msg: "remind test-notify: notification failed"}
+			}
+			return nil
+		},
+	}
+	testNotifyCmd.Flags().StringVar(&testMessage, "message", "margin test notification", "notification text")
+	testNotifyCmd.Flags().StringVar(&testNotifier, "notifier", "", "desktop|command|webhook (default: configured notifiers)")
+
+	remindCmd.AddCommand(scanCmd, scheduleCmd, digestCmd, nextCmd, listCmd, testNotifyCmd)
 	return remindCmd
 }
 
+func newReindexCmd() *cobra.Command {
+	var root string
+	var configPath string
+	var hidden bool
+
+	cmd := &cobra.Command{
+		Use:   "reindex",
+		Short: "Rebuild derived state from notes",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfigAndLayout(root, configPath)
+			if err != nil {
+				return err
+			}
+			unlock, err := lockRoot(root)
+			if err != nil {
+				return err
+			}
+			defer unlock()
+			res, err := remind.Rebuild(cmd.Context(), root, remind.ScanOptions{IncludeHistory: true, Hidden: hidden, DedupeByMessage: cfg.Remind.DedupeByMessage, DefaultMessage: cfg.Remind.DefaultMessage, ArchiveFired: cfg.Remind.ArchiveFired})
+			if err != nil {
+				return cliError{code: 1, msg: fmt.Sprintf("reindex: %v", err)}
+			}
+			writeJSON(map[string]any{"reminders": res})
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&hidden, "hidden", false, "include dot-prefixed files and directories")
+	cmd.Flags().StringVar(&root, "root", rootio.DefaultRoot(), "root")
+	cmd.Flags().StringVar(&configPath, "config", "", "config path")
+	return cmd
+}
+
+func newConfigCmd() *cobra.Command {
+	var root string
+	var configPath string
+
+	configCmd := &cobra.Command{
+		Use:   "config",
+		Short: "Read and update config values",
+	}
+	configCmd.PersistentFlags().StringVar(&root, "root", rootio.DefaultRoot(), "root")
+	configCmd.PersistentFlags().StringVar(&configPath, "config", "", "config path")
+
+	getCmd := &cobra.Command{
+		Use:   "get <key>",
+		Short: "Print a config value",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			v, err := config.Get(root, configPath, args[0])
+			if err != nil {
+				return cliError{code: 1, msg: fmt.Sprintf("config get: %v", err)}
+			}
+			writeJSON(map[string]any{"key": args[0], "value": v})
+			return nil
+		},
+	}
+
+	setCmd := &cobra.Command{
+		Use:   "set <key> <value>",
+		Short: "Update a config value",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			unlock, err := lockRoot(root)
+			if err != nil {
+				return err
+			}
+			defer unlock()
+			v, err := config.Set(root, configPath, args[0], args[1])
+			var ve *config.ValueError
+			if errors.As(err, &ve) {
+				return cliError{code: 2, msg: fmt.Sprintf("config set: %v", err)}
+			}
+			if err != nil {
+				return cliError{code: 1, msg: fmt.Sprintf("config set: %v", err)}
+			}
+			writeJSON(map[string]any{"key": args[0], "value": v})
+			return nil
+		},
+	}
+
+	migrateCmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Upgrade config.json to the current schema version",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			unlock, err := lockRoot(root)
+			if err != nil {
+				return err
+			}
+			defer unlock()
+			res, err := config.Migrate(root, configPath, time.Now())
+			if err != nil {
+				return cliError{code: 1, msg: fmt.Sprintf("config migrate: %v", err)}
+			}
+			writeJSON(res)
+			return nil
+		},
+	}
+
+	configCmd.AddCommand(getCmd, setCmd, migrateCmd)
+	return configCmd
+}
+
+func newInboxCmd() *cobra.Command {
+	var root string
+	var configPath string
+	var olderThan string
+	var trash bool
+
+	inboxCmd := &cobra.Command{
+		Use:   "inbox",
+		Short: "Inbox housekeeping",
+	}
+	inboxCmd.PersistentFlags().StringVar(&root, "root", rootio.DefaultRoot(), "root")
+	inboxCmd.PersistentFlags().StringVar(&configPath, "config", "", "config path")
+
+	archiveCmd := &cobra.Command{
+		Use:   "archive",
+		Short: "Move old inbox files into scratch history",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cutoff, err := search.ParseTimeBound(olderThan, time.Now())
+			if err != nil {
+				return cliError{code: 2, msg: fmt.Sprintf("invalid --older-than: %v", err)}
+			}
+			if _, err := loadConfigAndLayout(root, configPath); err != nil {
+				return err
+			}
+			unlock, err := lockRoot(root)
+			if err != nil {
+				return err
+			}
+			defer unlock()
+			res, err := inbox.Archive(cmd.Context(), root, inbox.ArchiveOptions{Cutoff: cutoff, Trash: trash})
+			if err != nil {
+				return cliError{code: 1, msg: fmt.Sprintf("inbox archive: %v", err)}
+			}
+			writeJSON(res)
+			return nil
+		},
+	}
+	archiveCmd.Flags().StringVar(&olderThan, "older-than", "30d", "archive files modified before RFC3339, YYYY-MM-DD, or relative (30d)")
+	archiveCmd.Flags().BoolVar(&trash, "trash", false, "move into .trash instead of scratch/history")
+
+	inboxCmd.AddCommand(archiveCmd)
+	return inboxCmd
+}
+
+func watchRemindScan(ctx context.Context, root string, interval time.Duration, opts remind.ScanOptions, schedule bool, cfg config.RemindConfig) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	now := time.Now()
+	for {
+		cycle, err := remindScanCycle(ctx, root, opts, schedule, cfg)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return cliError{code: 1, msg: fmt.Sprintf("remind scan: %v", err)}
+		}
+		if cycle != nil {
+			cycle["at"] = now.Format(time.RFC3339)
+			writeJSON(cycle)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case now = <-ticker.C:
+		}
+	}
+}
+
+func remindScanCycle(ctx context.Context, root string, opts remind.ScanOptions, schedule bool, cfg config.RemindConfig) (map[string]any, error) {
+	if !opts.DryRun {
+		lock, err := rootio.Lock(root, rootLockTimeout)
+		if errors.Is(err, rootio.ErrLocked) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("lock root: %w", err)
+		}
+		defer func() { _ = lock.Release() }()
+	}
+	res, err := remind.Scan(ctx, root, opts)
+	if err != nil {
+		return nil, err
+	}
+	cycle := map[string]any{"scan": res}
+	if schedule {
+		sched, err := remind.Schedule(ctx, root, remind.ScheduleOptions{Notify: true, CatchUp: remind.CatchUpFireAll, Config: cfg})
+		if err != nil {
+			return nil, err
+		}
+		cycle["schedule"] = sched
+	}
+	return cycle, nil
+}
+
+func newDaemonCmd() *cobra.Command {
+	var remindScan bool
+	var root string
+	var configPath string
+
+	cmd := &cobra.Command{
+		Use:   "daemon",
+		Short: "Snapshot scratch/current into history on the configured intervals",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfigAndLayout(root, configPath)
+			if err != nil {
+				return err
+			}
+			ctx := cmd.Context()
+			snap := snapshot.New(root, time.Duration(cfg.SnapshotIntervalMinutes)*time.Minute)
+			if err := snap.Baseline(time.Now()); err != nil {
+				return cliError{code: 1, msg: fmt.Sprintf("daemon: %v", err)}
+			}
+			ticker := time.NewTicker(time.Duration(cfg.AutosaveIntervalSeconds) * time.Second)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return nil
+				case now := <-ticker.C:
+					lock, err := rootio.Lock(root, rootLockTimeout)
+					if errors.Is(err, rootio.ErrLocked) {
+						continue
+					}
+					if err != nil {
+						return cliError{code: 1, msg: fmt.Sprintf("daemon: lock root: %v", err)}
+					}
+					created, err := snap.Tick(ctx, now)
+					if err == nil && remindScan && len(created) > 0 {
+						_, err = remind.Scan(ctx, root, remind.ScanOptions{DedupeByMessage: cfg.Remind.DedupeByMessage, DefaultMessage: cfg.Remind.DefaultMessage})
+					}
+					_ = lock.Release()
+					if ctx.Err() != nil {
+						return nil
+					}
+					if err != nil {
+						return cliError{code: 1, msg: fmt.Sprintf("daemon: %v", err)}
+					}
+					if len(created) > 0 {
+						writeJSON(map[string]any{"at": now.Format(time.RFC3339), "snapshots": created})
+					}
+				}
+			}
+		},
+	}
+	cmd.Flags().BoolVar(&remindScan, "remind-scan", false, "run remind scan after each tick that wrote snapshots")
+	cmd.Flags().StringVar(&root, "root", rootio.DefaultRoot(), "root")
+	cmd.Flags().StringVar(&configPath, "config", "", "config path")
+	return cmd
+}
+
+func newDoctorCmd() *cobra.Command {
+	var fix bool
+	var yes bool
+	var root string
+	var configPath string
+
+	cmd := &cobra.Command{
+		Use:   "doctor",
+		Short: "Check the root layout, config, and reminder store",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if yes && !fix {
+				return cliError{code: 2, msg: "--yes requires --fix"}
+			}
+			res, err := doctor.Run(cmd.Context(), root, doctor.Options{ConfigPath: configPath, Fix: fix, Yes: yes})
+			if err != nil {
+				return cliError{code: 1, msg: fmt.Sprintf("doctor: %v", err)}
+			}
+			writeJSON(res)
+			if !res.OK {
+				return cliError{code: 1, msg: "doctor: problems remain"}
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&fix, "fix", false, "create missing layout, config.json, and reminders.json")
+	cmd.Flags().BoolVar(&yes, "yes", false, "with --fix, back up and replace unparseable files")
+	cmd.Flags().StringVar(&root, "root", rootio.DefaultRoot(), "root")
+	cmd.Flags().StringVar(&configPath, "config", "", "config path")
+	return cmd
+}
+
+func newImportMDCmd() *cobra.Command {
+	var file string
+	var level int
+	var root string
+	var configPath string
+
+	cmd := &cobra.Command{
+		Use:   "import-md",
+		Short: "Split a markdown file into inbox notes at headings",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if file == "" {
+				return cliError{code: 2, msg: "--file required"}
+			}
+			if level < 1 || level > 6 {
+				return cliError{code: 2, msg: fmt.Sprintf("invalid --split-on-heading: %d", level)}
+			}
+			cfg, err := loadConfigAndLayout(root, configPath)
+			if err != nil {
+				return err
+			}
+			unlock, err := lockRoot(root)
+			if err != nil {
+				return err
+			}
+			defer unlock()
+			res, err := inbox.ImportMarkdown(cmd.Context(), root, file, inbox.ImportOptions{
+				Level: level,
+				Slug:  inbox.SlugOptions{MaxLen: cfg.Note.SlugMaxLen, Transliterate: cfg.Note.SlugTransliterate},
+			})
+			if err != nil {
+				return cliError{code: 1, msg: fmt.Sprintf("import-md: %v", err)}
+			}
+			writeJSON(res)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&file, "file", "", "markdown file to split")
+	cmd.Flags().IntVar(&level, "split-on-heading", 2, "heading level that starts a new note (1-6)")
+	cmd.Flags().StringVar(&root, "root", rootio.DefaultRoot(), "root")
+	cmd.Flags().StringVar(&configPath, "config", "", "config path")
+	return cmd
+}
+
+func newCatCmd() *cobra.Command {
+	var paths string
+	var glob string
+	var raw bool
+	var hidden bool
+	var maxBytes int
+	var stripFM bool
+	var root string
+	var configPath string
+
+	cmd := &cobra.Command{
+		Use:   "cat",
+		Short: "Print the contents of matching notes",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfigAndLayout(root, configPath)
+			if err != nil {
+				return err
+			}
+			groups := cfg.SearchPaths
+			if strings.TrimSpace(paths) != "" {
+				groups = splitCSV(paths)
+			}
+			res, err := notecat.Collect(cmd.Context(), root, groups, notecat.Options{Glob: glob, Hidden: hidden, MaxBytes: maxBytes, StripFrontMatter: stripFM})
+			if err != nil {
+				return cliError{code: 1, msg: fmt.Sprintf("cat: %v", err)}
+			}
+			if raw {
+				_, _ = fmt.Fprint(os.Stdout, res.Raw())
+				return nil
+			}
+			writeJSON(res)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&paths, "paths", "", "comma paths")
+	cmd.Flags().StringVar(&glob, "glob", "", "only files whose name matches this glob")
+	cmd.Flags().BoolVar(&raw, "raw", false, "print concatenated file contents instead of JSON")
+	cmd.Flags().BoolVar(&stripFM, "strip-front-matter", false, "drop a leading YAML front matter block (reported as front_matter in JSON)")
+	cmd.Flags().BoolVar(&hidden, "hidden", false, "include dot-prefixed files and directories")
+	cmd.Flags().IntVar(&maxBytes, "max-bytes", notecat.DefaultMaxBytes, "stop after this many bytes of content")
+	cmd.Flags().StringVar(&root, "root", rootio.DefaultRoot(), "root")
+	cmd.Flags().StringVar(&configPath, "config", "", "config path")
+	return cmd
+}
+
 func newRunBlockCmd() *cobra.Command {
 	var file string
 	var cursor string
+	var envFile string
+	var echoCode bool
+	var validateOnly bool
+	var indentedLang string
+	var shell string
+	var tieBreak string
 	var root string
 	var configPath string
 
@@ -207,7 +1027,36 @@ func newRunBlockCmd() *cobra.Command {
 			if err != nil {
 				return cliError{code: 2, msg: fmt.Sprintf("invalid --cursor: %v", err)}
 			}
-			res, err := runblock.Run(cmd.Context(), file, cur, cfg.RunBlock)
+			var env []string
+			if envFile != "" {
+				envPath := envFile
+				if !filepath.IsAbs(envPath) {
+					envPath = filepath.Join(root, envPath)
+					if _, err := rootio.RelUnderRoot(root, envPath); err != nil {
+						return cliError{code: 2, msg: fmt.Sprintf("invalid --env-file: %v", err)}
+					}
+				}
+				env, err = runblock.ParseEnvFile(envPath)
+				if err != nil {
+					return cliError{code: 1, msg: fmt.Sprintf("run-block: env file: %v", err)}
+				}
+			}
+			if cmd.Flags().Changed("indented-lang") {
+				cfg.RunBlock.IndentedLanguage = indentedLang
+			}
+			if cmd.Flags().Changed("tie-break") {
+				if !runblock.ValidTieBreak(tieBreak) {
+					return cliError{code: 2, msg: fmt.Sprintf("invalid --tie-break: %s", tieBreak)}
+				}
+				cfg.RunBlock.TieBreak = tieBreak
+			}
+			if cmd.Flags().Changed("shell") {
+				if err := runblock.CheckShell(shell); err != nil {
+					return cliError{code: 2, msg: fmt.Sprintf("invalid --shell: %v", err)}
+				}
+				cfg.RunBlock.Shell = shell
+			}
+			res, err := runblock.RunWithOptions(cmd.Context(), file, cur, cfg.RunBlock, runblock.RunOptions{Env: env, EchoCode: echoCode, ValidateOnly: validateOnly})
 			if err != nil {
 				return cliError{code: 1, msg: fmt.Sprintf("run-block: %v", err)}
 			}
@@ -217,6 +1066,49 @@ func newRunBlockCmd() *cobra.Command {
 	}
 	cmd.Flags().StringVar(&file, "file", "", "file path")
 	cmd.Flags().StringVar(&cursor, "cursor", "0", "cursor offset")
+	cmd.Flags().BoolVar(&validateOnly, "validate-only", false, "validate a json block against its $schema instead of pretty-printing")
+	cmd.Flags().BoolVar(&echoCode, "echo-code", false, "include the executed block source as code in the result")
+	cmd.Flags().StringVar(&indentedLang, "indented-lang", "", "treat 4-space indented code blocks as this language (empty = fenced only)")
+	cmd.Flags().StringVar(&envFile, "env-file", "", "dotenv file merged into the block environment (relative to root)")
+	cmd.Flags().StringVar(&tieBreak, "tie-break", "", "next|previous block when the cursor is equally far from both (default from runblock.tie_break, else next)")
+	cmd.Flags().StringVar(&shell, "shell", "", "shell for this run, tried before bash and sh (default from runblock.shell)")
+	cmd.Flags().StringVar(&root, "root", rootio.DefaultRoot(), "root")
+	cmd.Flags().StringVar(&configPath, "config", "", "config path")
+	cmd.AddCommand(newRunBlockListCmd())
+	return cmd
+}
+
+func newRunBlockListCmd() *cobra.Command {
+	var file string
+	var indentedLang string
+	var root string
+	var configPath string
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List code blocks in a file without running them",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig(root, configPath)
+			if err != nil {
+				return err
+			}
+			if file == "" {
+				return cliError{code: 2, msg: "--file required"}
+			}
+			if cmd.Flags().Changed("indented-lang") {
+				cfg.RunBlock.IndentedLanguage = indentedLang
+			}
+			b, err := os.ReadFile(file)
+			if err != nil {
+				return cliError{code: 1, msg: fmt.Sprintf("run-block list: %v", err)}
+			}
+			writeJSON(runblock.Describe(runblock.ParseBlocksWithIndented(string(b), cfg.RunBlock.IndentedLanguage)))
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&file, "file", "", "file path")
+	cmd.Flags().StringVar(&indentedLang, "indented-lang", "", "also list 4-space indented code blocks as this language")
 	cmd.Flags().StringVar(&root, "root", rootio.DefaultRoot(), "root")
 	cmd.Flags().StringVar(&configPath, "config", "", "config path")
 	return cmd
@@ -225,6 +1117,17 @@ func newRunBlockCmd() *cobra.Command {
 func newSlackCmd() *cobra.Command {
 	var transcript string
 	var format string
+	var style string
+	var anonymize bool
+	var redact bool
+	var keepMap bool
+	var includeParent bool
+	var repliesOnly bool
+	var teamDomain string
+	var channel string
+	var threaded bool
+	var redactPatterns []string
+	var includeDeleted bool
 	var root string
 	var configPath string
 
@@ -238,10 +1141,34 @@ func newSlackCmd() *cobra.Command {
 		Short: "Capture Slack transcript from pasted text",
 		Args:  cobra.NoArgs,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			if _, err := loadConfigAndLayout(root, configPath); err != nil {
+			if !slackcap.ValidStyle(style) {
+				return cliError{code: 2, msg: fmt.Sprintf("invalid --style: %s", style)}
+			}
+			redactRegexps, err := slackcap.CompileRedactPatterns(redactPatterns)
+			if err != nil {
+				return cliError{code: 2, msg: fmt.Sprintf("invalid --redact-pattern: %v", err)}
+			}
+			cfg, err := loadConfigAndLayout(root, configPath)
+			if err != nil {
 				return err
 			}
-			res, err := slackcap.Capture(cmd.Context(), root, transcript, format)
+			if !slackcap.ValidTimeFormat(cfg.Slack.TimeFormat) {
+				return cliError{code: 1, msg: fmt.Sprintf("invalid slack.time_format: %q", cfg.Slack.TimeFormat)}
+			}
+			res, err := slackcap.Capture(cmd.Context(), root, transcript, slackcap.CaptureOptions{
+				Format:         format,
+				Style:          style,
+				Anonymize:      anonymize,
+				Redact:         redact,
+				KeepMap:        keepMap,
+				ExcludeParent:  !includeParent || repliesOnly,
+				TeamDomain:     teamDomain,
+				Channel:        channel,
+				Threaded:       threaded,
+				RedactRegexps:  redactRegexps,
+				TimeFormat:     cfg.Slack.TimeFormat,
+				IncludeDeleted: includeDeleted,
+			})
 			if err != nil {
 				return cliError{code: 1, msg: fmt.Sprintf("slack capture: %v", err)}
 			}
@@ -251,6 +1178,17 @@ func newSlackCmd() *cobra.Command {
 	}
 	captureCmd.Flags().StringVar(&transcript, "transcript", "", "pasted Slack transcript text")
 	captureCmd.Flags().StringVar(&format, "format", "markdown", "markdown|text")
+	captureCmd.Flags().StringVar(&style, "style", slackcap.StyleDetailed, "detailed|compact|quoted (markdown format only)")
+	captureCmd.Flags().BoolVar(&anonymize, "anonymize", false, "replace user names with stable pseudonyms")
+	captureCmd.Flags().BoolVar(&redact, "redact", false, "redact email addresses and URLs in message text")
+	captureCmd.Flags().StringArrayVar(&redactPatterns, "redact-pattern", nil, "regex whose matches are replaced with [REDACTED] (repeatable)")
+	captureCmd.Flags().BoolVar(&includeDeleted, "include-deleted", false, "keep deleted messages as (deleted) placeholders instead of skipping them")
+	captureCmd.Flags().BoolVar(&includeParent, "include-parent", true, "include the thread root (first pasted message)")
+	captureCmd.Flags().BoolVar(&repliesOnly, "replies-only", false, "capture only replies; same as --include-parent=false")
+	captureCmd.Flags().StringVar(&teamDomain, "team-domain", "", "workspace domain (acme or acme.slack.com) for per-message permalinks")
+	captureCmd.Flags().StringVar(&channel, "channel", "", "channel ID for per-message permalinks (e.g. C0123ABC)")
+	captureCmd.Flags().BoolVar(&threaded, "threaded", false, "indent replies that quote an earlier message under it (detailed style)")
+	captureCmd.Flags().BoolVar(&keepMap, "keep-map", false, "include the pseudonym mapping in meta (with --anonymize)")
 	captureCmd.Flags().StringVar(&root, "root", rootio.DefaultRoot(), "root")
 	captureCmd.Flags().StringVar(&configPath, "config", "", "config path")
 
@@ -261,6 +1199,14 @@ func newSlackCmd() *cobra.Command {
 func newMCPCmd() *cobra.Command {
 	var transport string
 	var readonly string
+	var hidden bool
+	var includeBinary bool
+	var dumpTools bool
+	var cacheTTL time.Duration
+	var appendPaths string
+	var excludeHistory bool
+	var idleTimeout time.Duration
+	var maxResultBytes int
 	var root string
 	var configPath string
 
@@ -284,10 +1230,63 @@ func newMCPCmd() *cobra.Command {
 				}
 				ro = v
 			}
+			srv := mcpserver.New(root, ro, cfg.SearchPaths)
+			srv.Hidden = hidden
+			srv.IncludeBinary = includeBinary
+			srv.ReminderPath = cfg.MCPReminderPath
+			srv.MinQueryLength = cfg.Search.MinQueryLength
+			srv.MaxResults = cfg.Search.MaxResults
+			srv.DefaultReminderMessage = cfg.Remind.DefaultMessage
+			srv.DedupeReminders = cfg.Remind.DedupeByMessage
+			srv.ExcludeHistory = cfg.Search.ExcludeHistory
+			srv.Features = map[string]bool{
+				"slack":    cfg.SlackEnabled,
+				"remind":   cfg.RemindEnabled,
+				"runblock": cfg.RunBlockEnabled,
+				"write":    !ro,
+			}
+			if cmd.Flags().Changed("exclude-history") {
+				srv.ExcludeHistory = excludeHistory
+			}
+			allowed := cfg.MCPAppendPaths
+			if strings.TrimSpace(appendPaths) != "" {
+				allowed = splitCSV(appendPaths)
+			}
+			if srv.AppendPaths, err = mcpserver.NormalizeAppendPaths(allowed); err != nil {
+				return cliError{code: 2, msg: fmt.Sprintf("invalid append paths: %v", err)}
+			}
+			if cacheTTL > 0 {
+				srv.SearchCache = search.NewCache(0, cacheTTL)
+			}
+			if cfg.MCPIdleTimeout != "" {
+				if srv.IdleTimeout, err = time.ParseDuration(cfg.MCPIdleTimeout); err != nil || srv.IdleTimeout < 0 {
+					return cliError{code: 1, msg: fmt.Sprintf("invalid mcp_idle_timeout: %q", cfg.MCPIdleTimeout)}
+				}
+			}
+			srv.MaxResultBytes = cfg.MCPMaxResultBytes
+			if cmd.Flags().Changed("max-result-bytes") {
+				if maxResultBytes <= 0 {
+					return cliError{code: 2, msg: fmt.Sprintf("invalid --max-result-bytes: %d", maxResultBytes)}
+				}
+				srv.MaxResultBytes = maxResultBytes
+			}
+			if cmd.Flags().Changed("idle-timeout") {
+				if idleTimeout < 0 {
+					return cliError{code: 2, msg: "--idle-timeout must not be negative"}
+				}
+				srv.IdleTimeout = idleTimeout
+			}
+			if dumpTools {
+				tools, err := srv.ListTools(cmd.Context())
+				if err != nil {
+					return cliError{code: 1, msg: fmt.Sprintf("mcp tools: %v", err)}
+				}
+				writeJSON(tools)
+				return nil
+			}
 			if !cfg.MCPEnabled && readonly == "" {
 				return cliError{code: 1, msg: "mcp disabled in config; set mcp_enabled=true or pass --readonly explicitly to override"}
 			}
-			srv := mcpserver.New(root, ro, cfg.SearchPaths)
 			if err := srv.Run(cmd.Context()); err != nil {
 				return cliError{code: 1, msg: fmt.Sprintf("mcp server: %v", err)}
 			}
@@ -296,14 +1295,35 @@ func newMCPCmd() *cobra.Command {
 	}
 	cmd.Flags().StringVar(&transport, "transport", "stdio", "transport")
 	cmd.Flags().StringVar(&readonly, "readonly", "", "true|false")
+	cmd.Flags().BoolVar(&hidden, "hidden", false, "include dot-prefixed files and directories")
+	cmd.Flags().BoolVar(&includeBinary, "include-binary", false, "search and preview files that look binary")
+	cmd.Flags().BoolVar(&dumpTools, "dump-tools", false, "print advertised tool schemas as JSON and exit")
+	cmd.Flags().DurationVar(&cacheTTL, "search-cache-ttl", 0, "cache identical searches for this long (0 disables)")
+	cmd.Flags().IntVar(&maxResultBytes, "max-result-bytes", 0, "truncate search tool results past this many serialized bytes (default 262144; overrides mcp_max_result_bytes)")
+	cmd.Flags().DurationVar(&idleTimeout, "idle-timeout", 0, "exit when no client message arrives for this long (0 disables; overrides mcp_idle_timeout)")
+	cmd.Flags().StringVar(&appendPaths, "append-paths", "", "comma prefixes the append tool may write under (overrides mcp_append_paths)")
+	cmd.Flags().BoolVar(&excludeHistory, "exclude-history", false, "skip scratch/history in search and recent (default from search.exclude_history)")
 	cmd.Flags().StringVar(&root, "root", rootio.DefaultRoot(), "root")
 	cmd.Flags().StringVar(&configPath, "config", "", "config path")
 	return cmd
 }
 
+func resolveRootFlag(cmd *cobra.Command) error {
+	f := cmd.Flags().Lookup("root")
+	if f == nil {
+		return nil
+	}
+	return f.Value.Set(rootio.ResolveRoot(f.Value.String()))
+}
+
 func loadConfig(root, configPath string) (config.Config, error) {
 	cfg, _, err := config.Load(root, configPath)
 	if err != nil {
+		var pe *config.ParseError
+		if !configStrict && errors.As(err, &pe) {
+			_, _ = fmt.Fprintf(os.Stderr, "warning: %v; using defaults\n", pe)
+			return config.Default(), nil
+		}
 		return config.Config{}, cliError{code: 1, msg: fmt.Sprintf("load config: %v", err)}
 	}
 	return cfg, nil
@@ -320,6 +1340,14 @@ func loadConfigAndLayout(root, configPath string) (config.Config, error) {
 	return cfg, nil
 }
 
+func lockRoot(root string) (func(), error) {
+	lock, err := rootio.Lock(root, rootLockTimeout)
+	if err != nil {
+		return nil, cliError{code: 1, msg: fmt.Sprintf("lock root: %v", err)}
+	}
+	return func() { _ = lock.Release() }, nil
+}
+
 func splitCSV(s string) []string {
 	parts := strings.Split(s, ",")
 	out := make([]string, 0, len(parts))
@@ -2,8 +2,14 @@ package config
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
 )
 
 const (
@@ -11,10 +17,15 @@ const (
 	defaultSnapshotIntervalMinutes = 10
 	defaultPythonBin               = "python"
 	defaultShell                   = "bash"
+	defaultMCPReminderPath         = "inbox/reminders.md"
+	defaultMinQueryLength          = 1
+	defaultMaxResults              = 10000
 )
 
 var defaultSearchPaths = []string{"scratch", "inbox", "slack"}
 
+var defaultRemindNotifiers = []string{"desktop"}
+
 var defaultSyntaxExtensionMap = map[string]string{
 	"Plain Text": "md",
 	"Markdown":   "md",
@@ -24,46 +35,107 @@ var defaultSyntaxExtensionMap = map[string]string{
 }
 
 type RunBlockConfig struct {
-	PythonBin string `json:"python_bin"`
-	Shell     string `json:"shell"`
-	SQLCmd    string `json:"sql_cmd,omitempty"`
+	PythonBin        string `json:"python_bin"`
+	Shell            string `json:"shell"`
+	SQLCmd           string `json:"sql_cmd,omitempty"`
+	AllowShebang     bool   `json:"allow_shebang,omitempty"`
+	IndentedLanguage string `json:"indented_language,omitempty"`
+	TieBreak         string `json:"tie_break,omitempty"`
+}
+
+type SearchConfig struct {
+	MinQueryLength int  `json:"min_query_length"`
+	MaxResults     int  `json:"max_results"`
+	ExcludeHistory bool `json:"exclude_history,omitempty"`
+}
+
+type SlackConfig struct {
+	TimeFormat string `json:"time_format,omitempty"`
+}
+
+type NoteConfig struct {
+	SlugMaxLen        int  `json:"slug_max_len,omitempty"`
+	SlugTransliterate bool `json:"slug_transliterate,omitempty"`
+}
+
+type RemindConfig struct {
+	Notifiers        []string `json:"notifiers"`
+	Command          string   `json:"command,omitempty"`
+	WebhookURL       string   `json:"webhook_url,omitempty"`
+	DedupeByMessage  bool     `json:"dedupe_by_message,omitempty"`
+	NotifyMaxOverdue string   `json:"notify_max_overdue,omitempty"`
+	DefaultMessage   string   `json:"default_message,omitempty"`
+	ArchiveFired     bool     `json:"archive_fired,omitempty"`
 }
 
 type Config struct {
+	SchemaVersion           int               `json:"schema_version"`
 	AutosaveIntervalSeconds int               `json:"autosave_interval_seconds"`
 	SnapshotIntervalMinutes int               `json:"snapshot_interval_minutes"`
 	SearchPaths             []string          `json:"search_paths"`
 	RemindEnabled           bool              `json:"remind_enabled"`
 	SlackEnabled            bool              `json:"slack_enabled"`
+	RunBlockEnabled         bool              `json:"runblock_enabled"`
 	MCPEnabled              bool              `json:"mcp_enabled"`
 	MCPReadonly             bool              `json:"mcp_readonly"`
+	MCPReminderPath         string            `json:"mcp_reminder_path"`
+	MCPAppendPaths          []string          `json:"mcp_append_paths,omitempty"`
+	MCPIdleTimeout          string            `json:"mcp_idle_timeout,omitempty"`
+	MCPMaxResultBytes       int               `json:"mcp_max_result_bytes,omitempty"`
 	ForceMarkdownExtension  bool              `json:"force_markdown_extension"`
 	SyntaxExtensionMap      map[string]string `json:"syntax_extension_map"`
 	RunBlock                RunBlockConfig    `json:"runblock"`
+	Remind                  RemindConfig      `json:"remind"`
+	Search                  SearchConfig      `json:"search"`
+	Note                    NoteConfig        `json:"note"`
+	Slack                   SlackConfig       `json:"slack"`
+}
+
+type ParseError struct {
+	Path string
+	Err  error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("parse %s: %v", e.Path, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
 }
 
 func Default() Config {
 	return Config{
+		SchemaVersion:           SchemaVersion,
 		AutosaveIntervalSeconds: defaultAutosaveIntervalSeconds,
 		SnapshotIntervalMinutes: defaultSnapshotIntervalMinutes,
 		SearchPaths:             cloneStringSlice(defaultSearchPaths),
 		RemindEnabled:           false,
 		SlackEnabled:            false,
+		RunBlockEnabled:         true,
 		MCPEnabled:              false,
 		MCPReadonly:             true,
+		MCPReminderPath:         defaultMCPReminderPath,
 		ForceMarkdownExtension:  true,
 		SyntaxExtensionMap:      cloneStringMap(defaultSyntaxExtensionMap),
 		RunBlock: RunBlockConfig{
 			PythonBin: defaultPythonBin,
 			Shell:     defaultShell,
 		},
+		Remind: RemindConfig{
+			Notifiers: cloneStringSlice(defaultRemindNotifiers),
+		},
+		Search: SearchConfig{
+			MinQueryLength: defaultMinQueryLength,
+			MaxResults:     defaultMaxResults,
+		},
 	}
 }
 
 func Load(root, configPath string) (Config, string, error) {
 	cfg := Default()
 	if configPath == "" {
-		configPath = filepath.Join(root, "config.json")
+		configPath = defaultPath(root)
 	}
 	data, err := os.ReadFile(configPath)
 	if err != nil {
@@ -72,11 +144,46 @@ func Load(root, configPath string) (Config, string, error) {
 		}
 		return cfg, configPath, err
 	}
+	cfg, err = Parse(data)
+	var ve *ValueError
+	if errors.As(err, &ve) {
+		return cfg, configPath, fmt.Errorf("%s: %w", configPath, err)
+	}
+	if err != nil {
+		return cfg, configPath, &ParseError{Path: configPath, Err: err}
+	}
+	return cfg, configPath, nil
+}
+
+func Parse(data []byte) (Config, error) {
+	cfg := Default()
+	cfg.SchemaVersion = 0
 	if err := json.Unmarshal(data, &cfg); err != nil {
-		return cfg, configPath, err
+		return cfg, err
 	}
 	cfg.applyDefaults()
-	return cfg, configPath, nil
+	return cfg, cfg.validate()
+}
+
+var relativeDurationRe = regexp.MustCompile(`^(\d+)([mhdw])$`)
+
+func ParseRelativeDuration(raw string) (time.Duration, error) {
+	raw = strings.TrimSpace(raw)
+	if m := relativeDurationRe.FindStringSubmatch(raw); len(m) == 3 {
+		n, _ := strconv.Atoi(m[1])
+		unit := map[string]time.Duration{"m": time.Minute, "h": time.Hour, "d": 24 * time.Hour, "w": 7 * 24 * time.Hour}[m[2]]
+		return time.Duration(n) * unit, nil
+	}
+	// Anything else, such as 90s or 1h30m, is a plain Go duration.
+	d, err := time.ParseDuration(raw)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid duration %q: expected a duration like 90s, 1h30m, 7d, or 2w", raw)
+	}
+	return d, nil
+}
+
+func defaultPath(root string) string {
+	return filepath.Join(root, "config.json")
 }
 
 func (c *Config) applyDefaults() {
@@ -89,6 +196,9 @@ func (c *Config) applyDefaults() {
 	if len(c.SearchPaths) == 0 {
 		c.SearchPaths = cloneStringSlice(defaultSearchPaths)
 	}
+	if c.MCPReminderPath == "" {
+		c.MCPReminderPath = defaultMCPReminderPath
+	}
 	if c.SyntaxExtensionMap == nil {
 		c.SyntaxExtensionMap = cloneStringMap(defaultSyntaxExtensionMap)
 	}
@@ -98,6 +208,24 @@ func (c *Config) applyDefaults() {
 	if c.RunBlock.Shell == "" {
 		c.RunBlock.Shell = defaultShell
 	}
+	if len(c.Remind.Notifiers) == 0 {
+		c.Remind.Notifiers = cloneStringSlice(defaultRemindNotifiers)
+	}
+	if c.Search.MinQueryLength <= 0 {
+		c.Search.MinQueryLength = defaultMinQueryLength
+	}
+	if c.Search.MaxResults <= 0 {
+		c.Search.MaxResults = defaultMaxResults
+	}
+}
+
+func (c *Config) validate() error {
+	if c.Remind.NotifyMaxOverdue != "" {
+		if _, err := ParseRelativeDuration(c.Remind.NotifyMaxOverdue); err != nil {
+			return &ValueError{Key: "remind.notify_max_overdue", Err: err}
+		}
+	}
+	return nil
 }
 
 func cloneStringSlice(in []string) []string {
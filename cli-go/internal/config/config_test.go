@@ -1,9 +1,11 @@
 package config
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestDefaultReturnsIndependentCopies(t *testing.T) {
@@ -20,6 +22,19 @@ func TestDefaultReturnsIndependentCopies(t *testing.T) {
 	}
 }
 
+func TestLoadReturnsParseError(t *testing.T) {
+	root := t.TempDir()
+	configPath := filepath.Join(root, "config.json")
+	if err := os.WriteFile(configPath, []byte(`{"mcp_enabled": true,}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	_, _, err := Load(root, configPath)
+	var pe *ParseError
+	if !errors.As(err, &pe) || pe.Path != configPath {
+		t.Fatalf("expected parse error, got %v", err)
+	}
+}
+
 func TestLoadAppliesDefaultsForMissingValues(t *testing.T) {
 	root := t.TempDir()
 	configPath := filepath.Join(root, "config.json")
@@ -40,4 +55,40 @@ func TestLoadAppliesDefaultsForMissingValues(t *testing.T) {
 	if len(cfg.SearchPaths) == 0 {
 		t.Fatal("search paths should be defaulted")
 	}
+	if !cfg.RunBlockEnabled {
+		t.Fatal("runblock_enabled should default to true")
+	}
+	if err := os.WriteFile(configPath, []byte(`{"runblock_enabled":false}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if cfg, _, err = Load(root, configPath); err != nil || cfg.RunBlockEnabled {
+		t.Fatalf("explicit runblock_enabled=false not kept: %v %v", cfg.RunBlockEnabled, err)
+	}
+}
+
+func TestLoadValidatesNotifyMaxOverdue(t *testing.T) {
+	root := t.TempDir()
+	configPath := filepath.Join(root, "config.json")
+	if err := os.WriteFile(configPath, []byte(`{"remind":{"notify_max_overdue":"7d"}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := Load(root, configPath); err != nil {
+		t.Fatalf("7d should be accepted: %v", err)
+	}
+	for raw, want := range map[string]time.Duration{"7d": 7 * 24 * time.Hour, "2w": 14 * 24 * time.Hour, "90s": 90 * time.Second, "1h30m": 90 * time.Minute} {
+		if got, err := ParseRelativeDuration(raw); err != nil || got != want {
+			t.Fatalf("ParseRelativeDuration(%q) = %v, %v; want %v", raw, got, err, want)
+		}
+	}
+	if _, err := ParseRelativeDuration("-1h"); err == nil {
+		t.Fatal("negative durations should be rejected")
+	}
+	if err := os.WriteFile(configPath, []byte(`{"remind":{"notify_max_overdue":"soon"}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	_, _, err := Load(root, configPath)
+	var pe *ParseError
+	if err == nil || errors.As(err, &pe) {
+		t.Fatalf("expected a validation error, got %v", err)
+	}
 }
@@ -0,0 +1,133 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+
+	"margin/internal/rootio"
+)
+
+type ValueError struct {
+	Key string
+	Err error
+}
+
+func (e *ValueError) Error() string {
+	return fmt.Sprintf("invalid value for %s: %v", e.Key, e.Err)
+}
+
+func (e *ValueError) Unwrap() error {
+	return e.Err
+}
+
+func Get(root, configPath, key string) (any, error) {
+	cfg, _, err := Load(root, configPath)
+	if err != nil {
+		return nil, err
+	}
+	field, err := fieldByPath(reflect.ValueOf(&cfg).Elem(), key)
+	if err != nil {
+		return nil, err
+	}
+	return field.Interface(), nil
+}
+
+func Set(root, configPath, key, value string) (any, error) {
+	if configPath == "" {
+		configPath = defaultPath(root)
+	}
+	var cfg Config
+	field, err := fieldByPath(reflect.ValueOf(&cfg).Elem(), key)
+	if err != nil {
+		return nil, err
+	}
+	parsed, err := parseValue(field.Type(), value)
+	if err != nil {
+		return nil, &ValueError{Key: key, Err: err}
+	}
+
+	raw := map[string]any{}
+	data, err := os.ReadFile(configPath)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return nil, fmt.Errorf("parse %s: %w", configPath, err)
+		}
+	}
+	parts := strings.Split(key, ".")
+	node := raw
+	for _, p := range parts[:len(parts)-1] {
+		child, ok := node[p].(map[string]any)
+		if !ok {
+			child = map[string]any{}
+			node[p] = child
+		}
+		node = child
+	}
+	node[parts[len(parts)-1]] = parsed
+
+	out, err := json.MarshalIndent(raw, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	// Refuse values Load would reject, so a bad set cannot lock every command out.
+	if _, err := Parse(out); err != nil {
+		return nil, err
+	}
+	if err := rootio.AtomicReplaceFile(configPath, append(out, '\n'), 0o644); err != nil {
+		return nil, err
+	}
+	return parsed, nil
+}
+
+func fieldByPath(v reflect.Value, key string) (reflect.Value, error) {
+	if strings.TrimSpace(key) == "" {
+		return reflect.Value{}, errors.New("key is required")
+	}
+	for _, part := range strings.Split(key, ".") {
+		if v.Kind() != reflect.Struct {
+			return reflect.Value{}, fmt.Errorf("unknown config key: %s", key)
+		}
+		found := false
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+			if name == part {
+				v = v.Field(i)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return reflect.Value{}, fmt.Errorf("unknown config key: %s", key)
+		}
+	}
+	return v, nil
+}
+
+func parseValue(t reflect.Type, value string) (any, error) {
+	if t.Kind() == reflect.String {
+		return value, nil
+	}
+	if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.String && !strings.HasPrefix(strings.TrimSpace(value), "[") {
+		parts := strings.Split(value, ",")
+		out := make([]string, 0, len(parts))
+		for _, p := range parts {
+			if p = strings.TrimSpace(p); p != "" {
+				out = append(out, p)
+			}
+		}
+		return out, nil
+	}
+	ptr := reflect.New(t)
+	if err := json.Unmarshal([]byte(value), ptr.Interface()); err != nil {
+		return nil, fmt.Errorf("expected %s", t.Kind())
+	}
+	return ptr.Elem().Interface(), nil
+}
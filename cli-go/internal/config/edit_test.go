@@ -0,0 +1,55 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSetPreservesOtherFieldsAndValidatesType(t *testing.T) {
+	root := t.TempDir()
+	configPath := filepath.Join(root, "config.json")
+	if err := os.WriteFile(configPath, []byte(`{"search_paths":["inbox"],"custom":"keep"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Set(root, "", "mcp_enabled", "true"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Set(root, "", "runblock.python_bin", "python3"); err != nil {
+		t.Fatal(err)
+	}
+	var ve *ValueError
+	if _, err := Set(root, "", "autosave_interval_seconds", "soon"); !errors.As(err, &ve) {
+		t.Fatalf("expected type error, got %v", err)
+	}
+	if _, err := Set(root, "", "remind.notify_max_overdue", "-1"); !errors.As(err, &ve) {
+		t.Fatalf("expected validation error, got %v", err)
+	}
+	if _, _, err := Load(root, configPath); err != nil {
+		t.Fatalf("rejected value should not reach the file: %v", err)
+	}
+	if _, err := Set(root, "", "nope", "1"); err == nil {
+		t.Fatal("expected unknown key error")
+	}
+
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var raw map[string]any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		t.Fatal(err)
+	}
+	if raw["custom"] != "keep" || raw["mcp_enabled"] != true {
+		t.Fatalf("unexpected config: %s", data)
+	}
+	got, err := Get(root, "", "runblock.python_bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "python3" {
+		t.Fatalf("python_bin=%v", got)
+	}
+}
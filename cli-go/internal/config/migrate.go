@@ -0,0 +1,133 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"margin/internal/rootio"
+)
+
+const SchemaVersion = 1
+
+const schemaVersionKey = "schema_version"
+
+var migrations = []func(raw map[string]any){
+	// 0 -> 1: versioning introduced; missing fields are filled from defaults below.
+	func(map[string]any) {},
+}
+
+type MigrateResult struct {
+	Path    string   `json:"path"`
+	From    int      `json:"from"`
+	To      int      `json:"to"`
+	Added   []string `json:"added,omitempty"`
+	Changed bool     `json:"changed"`
+	Backup  string   `json:"backup,omitempty"`
+}
+
+func Migrate(root, configPath string, now time.Time) (MigrateResult, error) {
+	if configPath == "" {
+		configPath = defaultPath(root)
+	}
+	res := MigrateResult{Path: configPath, To: SchemaVersion}
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return res, err
+	}
+	raw := map[string]any{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return res, &ParseError{Path: configPath, Err: err}
+	}
+	from, err := rawSchemaVersion(raw)
+	if err != nil {
+		return res, err
+	}
+	res.From = from
+	if from > SchemaVersion {
+		return res, fmt.Errorf("schema_version %d is newer than supported version %d", from, SchemaVersion)
+	}
+	for v := from; v < SchemaVersion; v++ {
+		migrations[v](raw)
+	}
+	defaults, err := defaultsMap()
+	if err != nil {
+		return res, err
+	}
+	delete(defaults, schemaVersionKey)
+	res.Added = fillMissing(raw, defaults, "")
+	sort.Strings(res.Added)
+	raw[schemaVersionKey] = SchemaVersion
+	res.Changed = from != SchemaVersion || len(res.Added) > 0
+	if !res.Changed {
+		return res, nil
+	}
+
+	out, err := json.MarshalIndent(raw, "", "  ")
+	if err != nil {
+		return res, err
+	}
+	var check Config
+	if err := json.Unmarshal(out, &check); err != nil {
+		return res, fmt.Errorf("migrated config does not load: %w", err)
+	}
+	backup := rootio.TrashPath(root, now, filepath.Base(configPath))
+	if err := rootio.AtomicWriteFile(backup, data, 0o644); err != nil {
+		return res, err
+	}
+	if rel, err := rootio.RelUnderRoot(root, backup); err == nil {
+		res.Backup = rel
+	} else {
+		res.Backup = backup
+	}
+	if err := rootio.AtomicReplaceFile(configPath, append(out, '\n'), 0o644); err != nil {
+		return res, err
+	}
+	return res, nil
+}
+
+func rawSchemaVersion(raw map[string]any) (int, error) {
+	v, ok := raw[schemaVersionKey]
+	if !ok {
+		return 0, nil
+	}
+	n, ok := v.(float64)
+	if !ok || n < 0 || n != float64(int(n)) {
+		return 0, errors.New("schema_version must be a non-negative integer")
+	}
+	return int(n), nil
+}
+
+func defaultsMap() (map[string]any, error) {
+	b, err := json.Marshal(Default())
+	if err != nil {
+		return nil, err
+	}
+	out := map[string]any{}
+	if err := json.Unmarshal(b, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func fillMissing(raw, defaults map[string]any, prefix string) []string {
+	var added []string
+	for k, dv := range defaults {
+		cur, ok := raw[k]
+		if !ok {
+			raw[k] = dv
+			added = append(added, prefix+k)
+			continue
+		}
+		curMap, curOK := cur.(map[string]any)
+		defMap, defOK := dv.(map[string]any)
+		if curOK && defOK {
+			added = append(added, fillMissing(curMap, defMap, prefix+k+".")...)
+		}
+	}
+	return added
+}
@@ -0,0 +1,72 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMigrateUpgradesLegacyConfig(t *testing.T) {
+	root := t.TempDir()
+	configPath := filepath.Join(root, "config.json")
+	legacy := `{"mcp_enabled": true, "runblock": {"shell": "zsh"}, "auto_replace_scratch_tab_with_file": false}`
+	if err := os.WriteFile(configPath, []byte(legacy), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, _, err := Load(root, configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.SchemaVersion != 0 {
+		t.Fatalf("legacy config should load as version 0, got %d", cfg.SchemaVersion)
+	}
+
+	now := time.Date(2026, 3, 1, 9, 30, 0, 0, time.UTC)
+	res, err := Migrate(root, configPath, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Changed || res.From != 0 || res.To != SchemaVersion || res.Backup != ".trash/2026-03-01/093000-config.json" {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	if backup, _ := os.ReadFile(filepath.Join(root, filepath.FromSlash(res.Backup))); string(backup) != legacy {
+		t.Fatalf("backup=%q", backup)
+	}
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw := map[string]any{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		t.Fatal(err)
+	}
+	runblock := raw["runblock"].(map[string]any)
+	if raw["mcp_enabled"] != true || runblock["shell"] != "zsh" || runblock["python_bin"] != defaultPythonBin {
+		t.Fatalf("existing values must survive and missing ones be defaulted: %s", data)
+	}
+	if raw["auto_replace_scratch_tab_with_file"] != false {
+		t.Fatalf("unknown keys used by the plugin must be kept: %s", data)
+	}
+	cfg, _, err = Load(root, configPath)
+	if err != nil || cfg.SchemaVersion != SchemaVersion {
+		t.Fatalf("migrated config should load at current version: %+v %v", cfg.SchemaVersion, err)
+	}
+
+	again, err := Migrate(root, configPath, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Changed || again.Backup != "" || !reflect.DeepEqual(again.Added, []string(nil)) {
+		t.Fatalf("second migrate should be a no-op: %+v", again)
+	}
+
+	if err := os.WriteFile(configPath, []byte(`{"schema_version": 99}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Migrate(root, configPath, now); err == nil {
+		t.Fatal("expected error for a newer schema version")
+	}
+}
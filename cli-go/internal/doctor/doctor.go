@@ -0,0 +1,184 @@
+package doctor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"margin/internal/config"
+	"margin/internal/remind"
+	"margin/internal/rootio"
+)
+
+const (
+	StatusOK      = "ok"
+	StatusMissing = "missing"
+	StatusInvalid = "invalid"
+)
+
+type Options struct {
+	ConfigPath string
+	Fix        bool
+	Yes        bool
+	Now        time.Time
+}
+
+type Check struct {
+	Name              string `json:"name"`
+	Path              string `json:"path"`
+	Before            string `json:"before"`
+	After             string `json:"after"`
+	Fixed             bool   `json:"fixed,omitempty"`
+	NeedsConfirmation bool   `json:"needs_confirmation,omitempty"`
+	Detail            string `json:"detail,omitempty"`
+}
+
+type Result struct {
+	OK     bool    `json:"ok"`
+	Checks []Check `json:"checks"`
+}
+
+func Run(ctx context.Context, root string, opts Options) (Result, error) {
+	if opts.Now.IsZero() {
+		opts.Now = time.Now()
+	}
+	configPath := opts.ConfigPath
+	if configPath == "" {
+		configPath = filepath.Join(root, "config.json")
+	}
+	res := Result{OK: true}
+	steps := []func() (Check, error){
+		func() (Check, error) { return checkLayout(root, opts) },
+		func() (Check, error) {
+			c, err := checkJSONFile(root, "config", configPath, validConfig, defaultConfig, opts)
+			if err == nil && c.After == StatusOK && c.Detail == "" {
+				c.Detail = schemaNote(configPath)
+			}
+			return c, err
+		},
+		func() (Check, error) {
+			return checkJSONFile(root, "reminders", remind.StorePath(root), validStore, emptyStore, opts)
+		},
+	}
+	for _, step := range steps {
+		if err := ctx.Err(); err != nil {
+			return res, err
+		}
+		c, err := step()
+		if err != nil {
+			return res, fmt.Errorf("%s: %w", c.Name, err)
+		}
+		if c.After != StatusOK {
+			res.OK = false
+		}
+		res.Checks = append(res.Checks, c)
+	}
+	return res, nil
+}
+
+func checkLayout(root string, opts Options) (Check, error) {
+	c := Check{Name: "layout", Path: root, Before: StatusOK}
+	var missing []string
+	for _, d := range rootio.LayoutDirs(root) {
+		if st, err := os.Stat(d); err != nil || !st.IsDir() {
+			rel, _ := filepath.Rel(root, d)
+			missing = append(missing, filepath.ToSlash(rel))
+		}
+	}
+	if len(missing) > 0 {
+		c.Before = StatusMissing
+		c.Detail = fmt.Sprintf("missing %v", missing)
+	}
+	c.After = c.Before
+	if c.Before != StatusOK && opts.Fix {
+		if err := rootio.EnsureLayout(root); err != nil {
+			return c, err
+		}
+		c.After, c.Fixed = StatusOK, true
+	}
+	return c, nil
+}
+
+func checkJSONFile(root, name, path string, validate func([]byte) error, fresh func() ([]byte, error), opts Options) (Check, error) {
+	c := Check{Name: name, Path: path, Before: StatusOK}
+	data, err := os.ReadFile(path)
+	switch {
+	case os.IsNotExist(err):
+		c.Before = StatusMissing
+	case err != nil:
+		return c, err
+	default:
+		if verr := validate(data); verr != nil {
+			c.Before = StatusInvalid
+			c.Detail = verr.Error()
+		}
+	}
+	c.After = c.Before
+	if c.Before == StatusOK || !opts.Fix {
+		return c, nil
+	}
+	if c.Before == StatusInvalid {
+		if !opts.Yes {
+			c.NeedsConfirmation = true
+			return c, nil
+		}
+		backup := rootio.TrashPath(root, opts.Now, filepath.Base(path))
+		if err := os.MkdirAll(filepath.Dir(backup), 0o755); err != nil {
+			return c, err
+		}
+		if err := os.Rename(path, backup); err != nil {
+			return c, err
+		}
+		if rel, err := rootio.RelUnderRoot(root, backup); err == nil {
+			backup = rel
+		}
+		c.Detail += "; moved to " + backup
+	} else if _, err := os.Stat(path); err == nil {
+		return c, nil
+	}
+	b, err := fresh()
+	if err != nil {
+		return c, err
+	}
+	if err := rootio.AtomicWriteFile(path, b, 0o644); err != nil {
+		return c, err
+	}
+	c.After, c.Fixed = StatusOK, true
+	return c, nil
+}
+
+func validConfig(data []byte) error {
+	_, err := config.Parse(data)
+	return err
+}
+
+func schemaNote(path string) string {
+	// An old schema still loads, so it is reported here rather than failing the check.
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	cfg, err := config.Parse(data)
+	if err != nil || cfg.SchemaVersion >= config.SchemaVersion {
+		return ""
+	}
+	return fmt.Sprintf("schema_version %d is older than %d; run `margin config migrate`", cfg.SchemaVersion, config.SchemaVersion)
+}
+
+func defaultConfig() ([]byte, error) {
+	b, err := json.MarshalIndent(config.Default(), "", "  ")
+	return append(b, '\n'), err
+}
+
+func validStore(data []byte) error {
+	var st remind.Store
+	return json.Unmarshal(data, &st)
+}
+
+func emptyStore() ([]byte, error) {
+	b, err := json.MarshalIndent(remind.Store{Entries: []remind.Entry{}}, "", "  ")
+	return append(b, '\n'), err
+}
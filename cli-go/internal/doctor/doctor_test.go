@@ -0,0 +1,103 @@
+package doctor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunReportsAndFixesFreshRoot(t *testing.T) {
+	root := t.TempDir()
+	res, err := Run(context.Background(), root, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.OK || len(res.Checks) != 3 {
+		t.Fatalf("unexpected diagnosis: %+v", res)
+	}
+	for _, c := range res.Checks {
+		if c.Before != StatusMissing || c.After != StatusMissing || c.Fixed {
+			t.Fatalf("diagnosis should not change anything: %+v", c)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(root, "inbox")); !os.IsNotExist(err) {
+		t.Fatalf("diagnosis created layout: %v", err)
+	}
+
+	res, err = Run(context.Background(), root, Options{Fix: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.OK {
+		t.Fatalf("expected fixes to succeed: %+v", res)
+	}
+	for _, c := range res.Checks {
+		if !c.Fixed || c.After != StatusOK {
+			t.Fatalf("unexpected check: %+v", c)
+		}
+	}
+	for _, p := range []string{"inbox", "config.json", "index/reminders.json"} {
+		if _, err := os.Stat(filepath.Join(root, p)); err != nil {
+			t.Fatalf("%s not created: %v", p, err)
+		}
+	}
+}
+
+func TestRunFixRequiresConfirmationForInvalidFiles(t *testing.T) {
+	root := t.TempDir()
+	configPath := filepath.Join(root, "config.json")
+	if err := os.WriteFile(configPath, []byte("{broken"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	res, err := Run(context.Background(), root, Options{Fix: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := res.Checks[1]
+	if cfg.Before != StatusInvalid || cfg.After != StatusInvalid || !cfg.NeedsConfirmation || res.OK {
+		t.Fatalf("unexpected config check: %+v", cfg)
+	}
+	if data, _ := os.ReadFile(configPath); string(data) != "{broken" {
+		t.Fatalf("invalid config overwritten without confirmation: %q", data)
+	}
+
+	now := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	res, err = Run(context.Background(), root, Options{Fix: true, Yes: true, Now: now})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.OK || !res.Checks[1].Fixed {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	if data, _ := os.ReadFile(filepath.Join(root, ".trash", "2026-03-01", "090000-config.json")); string(data) != "{broken" {
+		t.Fatalf("backup missing: %q", data)
+	}
+
+	if err := os.WriteFile(configPath, []byte(`{"remind":{"notify_max_overdue":"soon"}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	res, err = Run(context.Background(), root, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := res.Checks[1]; c.Before != StatusInvalid || res.OK {
+		t.Fatalf("config that Load rejects reported as %+v", c)
+	}
+}
+
+func TestRunNotesOutdatedConfigSchema(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "config.json"), []byte(`{}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	res, err := Run(context.Background(), root, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := res.Checks[1]; c.After != StatusOK || !strings.Contains(c.Detail, "margin config migrate") {
+		t.Fatalf("outdated schema should be noted without failing the check: %+v", c)
+	}
+}
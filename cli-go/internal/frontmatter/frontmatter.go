@@ -0,0 +1,33 @@
+package frontmatter
+
+import (
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+func Split(content string) (map[string]any, string, bool) {
+	first, rest, found := strings.Cut(content, "\n")
+	if !found || strings.TrimRight(first, "\r") != "---" {
+		return nil, content, false
+	}
+	offset := 0
+	for offset <= len(rest) {
+		line, next, more := strings.Cut(rest[offset:], "\n")
+		if fence := strings.TrimRight(line, " \t\r"); fence == "---" || fence == "..." {
+			meta := map[string]any{}
+			if err := yaml.Unmarshal([]byte(rest[:offset]), &meta); err != nil {
+				return nil, content, false
+			}
+			if !more {
+				return meta, "", true
+			}
+			return meta, next, true
+		}
+		if !more {
+			break
+		}
+		offset += len(line) + 1
+	}
+	return nil, content, false
+}
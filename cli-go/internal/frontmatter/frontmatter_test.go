@@ -0,0 +1,21 @@
+package frontmatter
+
+import "testing"
+
+func TestSplitStripsLeadingBlock(t *testing.T) {
+	meta, body, ok := Split("---\r\ntitle: Plan\ntags: [a, b]\n---\n# Body\n---\nnot front matter\n")
+	if !ok || body != "# Body\n---\nnot front matter\n" {
+		t.Fatalf("ok=%v body=%q", ok, body)
+	}
+	if meta["title"] != "Plan" || len(meta["tags"].([]any)) != 2 {
+		t.Fatalf("meta=%v", meta)
+	}
+	if _, body, ok := Split("---\ntitle: x\n...\n"); !ok || body != "" {
+		t.Fatalf("ok=%v body=%q", ok, body)
+	}
+	for _, in := range []string{"# no front matter\n", "---\nunterminated: true\n", "---\n: [bad\n---\nbody", "text\n---\na: 1\n---\n"} {
+		if meta, body, ok := Split(in); ok || meta != nil || body != in {
+			t.Fatalf("%q: expected untouched content, got ok=%v meta=%v body=%q", in, ok, meta, body)
+		}
+	}
+}
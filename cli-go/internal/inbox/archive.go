@@ -0,0 +1,102 @@
+package inbox
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"margin/internal/rootio"
+	"margin/internal/snapshot"
+)
+
+type ArchiveOptions struct {
+	Cutoff time.Time
+	Trash  bool
+	Now    time.Time
+}
+
+type MovedFile struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type ArchiveResult struct {
+	Moved []MovedFile `json:"moved"`
+}
+
+func Archive(ctx context.Context, root string, opts ArchiveOptions) (ArchiveResult, error) {
+	res := ArchiveResult{Moved: []MovedFile{}}
+	now := opts.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+	inboxDir := filepath.Join(root, "inbox")
+	trashDir := filepath.Join(root, ".trash", now.Format("2006-01-02"), "inbox")
+	files, err := rootio.ListFilesRecursive([]string{inboxDir}, rootio.WalkOptions{})
+	if err != nil {
+		return res, err
+	}
+	for _, f := range files {
+		if err := ctx.Err(); err != nil {
+			return res, err
+		}
+		st, err := os.Stat(f)
+		if err != nil || !st.ModTime().Before(opts.Cutoff) {
+			continue
+		}
+		from, err := rootio.RelUnderRoot(root, f)
+		if err != nil {
+			continue
+		}
+		rel, err := filepath.Rel(inboxDir, f)
+		if err != nil {
+			continue
+		}
+		// Archived notes sit with the plugin's snapshots; trashed ones keep their inbox layout.
+		dest := snapshot.HistoryPath(root, f, now)
+		if opts.Trash {
+			dest = filepath.Join(trashDir, rel)
+		}
+		if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+			return res, err
+		}
+		dest, err = reservePath(dest)
+		if err != nil {
+			return res, fmt.Errorf("%s: %w", from, err)
+		}
+		to, err := rootio.RelUnderRoot(root, dest)
+		if err != nil {
+			_ = os.Remove(dest)
+			return res, fmt.Errorf("%s: %w", from, err)
+		}
+		if err := os.Rename(f, dest); err != nil {
+			_ = os.Remove(dest)
+			return res, fmt.Errorf("%s: %w", from, err)
+		}
+		res.Moved = append(res.Moved, MovedFile{From: from, To: to})
+	}
+	return res, nil
+}
+
+func reservePath(p string) (string, error) {
+	// Creating the destination with O_EXCL claims the name, so the rename that
+	// follows only ever replaces our own empty placeholder, never another file.
+	ext := filepath.Ext(p)
+	base := p[:len(p)-len(ext)]
+	for i := 1; ; i++ {
+		candidate := p
+		if i > 1 {
+			candidate = base + "-" + strconv.Itoa(i) + ext
+		}
+		f, err := os.OpenFile(candidate, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if err == nil {
+			return candidate, f.Close()
+		}
+		if !os.IsExist(err) {
+			return "", err
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package inbox
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestArchiveMovesOldInboxFiles(t *testing.T) {
+	root := t.TempDir()
+	inbox := filepath.Join(root, "inbox")
+	if err := os.MkdirAll(filepath.Join(inbox, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	old := filepath.Join(inbox, "sub", "old.md")
+	fresh := filepath.Join(inbox, "fresh.md")
+	for _, p := range []string{old, fresh} {
+		if err := os.WriteFile(p, []byte("x\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.Local)
+	if err := os.Chtimes(old, now.AddDate(0, 0, -40), now.AddDate(0, 0, -40)); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(fresh, now, now); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := Archive(context.Background(), root, ArchiveOptions{Cutoff: now.AddDate(0, 0, -30), Now: now})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "scratch/history/2026/2026-03-01/20260301T120000000000_old.md"
+	if len(res.Moved) != 1 || res.Moved[0].From != "inbox/sub/old.md" || res.Moved[0].To != want {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	if _, err := os.Stat(fresh); err != nil {
+		t.Fatalf("fresh file should stay: %v", err)
+	}
+
+	if err := os.WriteFile(old, []byte("y\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(old, now.AddDate(0, 0, -40), now.AddDate(0, 0, -40)); err != nil {
+		t.Fatal(err)
+	}
+	res, err = Archive(context.Background(), root, ArchiveOptions{Cutoff: now.AddDate(0, 0, -30), Now: now, Trash: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Moved) != 1 || res.Moved[0].To != ".trash/2026-03-01/inbox/sub/old.md" {
+		t.Fatalf("unexpected trash result: %+v", res)
+	}
+}
+
+func TestReservePathNeverReusesExistingFiles(t *testing.T) {
+	dir := t.TempDir()
+	taken := filepath.Join(dir, "note.md")
+	if err := os.WriteFile(taken, []byte("keep\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	first, err := reservePath(taken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := reservePath(taken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != filepath.Join(dir, "note-2.md") || second != filepath.Join(dir, "note-3.md") {
+		t.Fatalf("unexpected reservations: %s, %s", first, second)
+	}
+	if data, _ := os.ReadFile(taken); string(data) != "keep\n" {
+		t.Fatalf("existing file touched: %q", data)
+	}
+}
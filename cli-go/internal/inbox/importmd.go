@@ -0,0 +1,95 @@
+package inbox
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+
+	"margin/internal/rootio"
+)
+
+var slugUnsafe = regexp.MustCompile(`[^\p{L}\p{N}]+`)
+
+type ImportOptions struct {
+	Level int
+	Slug  SlugOptions
+}
+
+type ImportResult struct {
+	Created []string `json:"created"`
+}
+
+type section struct {
+	title string
+	start int
+}
+
+func ImportMarkdown(ctx context.Context, root, file string, opts ImportOptions) (ImportResult, error) {
+	res := ImportResult{Created: []string{}}
+	if opts.Level < 1 || opts.Level > 6 {
+		return res, fmt.Errorf("heading level must be 1-6, got %d", opts.Level)
+	}
+	src, err := os.ReadFile(file)
+	if err != nil {
+		return res, err
+	}
+	sections := splitSections(src, opts.Level)
+	if len(sections) == 0 {
+		return res, fmt.Errorf("no level-%d headings found", opts.Level)
+	}
+	if strings.TrimSpace(string(src[:sections[0].start])) != "" {
+		base := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+		sections = append([]section{{title: base, start: 0}}, sections...)
+	}
+	dir := filepath.Join(root, "inbox")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return res, err
+	}
+	for i, sec := range sections {
+		if err := ctx.Err(); err != nil {
+			return res, err
+		}
+		end := len(src)
+		if i+1 < len(sections) {
+			end = sections[i+1].start
+		}
+		body := strings.TrimRight(string(src[sec.start:end]), " \t\r\n") + "\n"
+		dest, err := reservePath(filepath.Join(dir, Slugify(sec.title, opts.Slug)+".md"))
+		if err != nil {
+			return res, err
+		}
+		if err := rootio.AtomicWriteFile(dest, []byte(body), 0o644); err != nil {
+			return res, err
+		}
+		rel, err := rootio.RelUnderRoot(root, dest)
+		if err != nil {
+			return res, err
+		}
+		res.Created = append(res.Created, rel)
+	}
+	return res, nil
+}
+
+func splitSections(src []byte, level int) []section {
+	doc := goldmark.New().Parser().Parse(text.NewReader(src))
+	var out []section
+	for n := doc.FirstChild(); n != nil; n = n.NextSibling() {
+		h, ok := n.(*ast.Heading)
+		if !ok || h.Level != level || h.Lines().Len() == 0 {
+			continue
+		}
+		start := h.Lines().At(0).Start
+		for start > 0 && src[start-1] != '\n' {
+			start--
+		}
+		out = append(out, section{title: string(h.Lines().Value(src)), start: start})
+	}
+	return out
+}
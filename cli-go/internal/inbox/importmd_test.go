@@ -0,0 +1,94 @@
+package inbox
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestImportMarkdownSplitsOnHeadings(t *testing.T) {
+	root := t.TempDir()
+	src := filepath.Join(t.TempDir(), "journal.md")
+	journal := "# Journal\n\nintro\n\n## 2024-01-01\n\nfirst day\n\n### detail\n\nmore\n\n## 2024-01-02 Trip!\n\nsecond day\n```\n## not a heading\n```\n"
+	if err := os.WriteFile(src, []byte(journal), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(root, "inbox"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "inbox", "2024-01-01.md"), []byte("existing\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := ImportMarkdown(context.Background(), root, src, ImportOptions{Level: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"inbox/journal.md", "inbox/2024-01-01-2.md", "inbox/2024-01-02-trip.md"}
+	if !reflect.DeepEqual(res.Created, want) {
+		t.Fatalf("created=%v want %v", res.Created, want)
+	}
+	cases := map[string]string{
+		"journal.md":         "# Journal\n\nintro\n",
+		"2024-01-01-2.md":    "## 2024-01-01\n\nfirst day\n\n### detail\n\nmore\n",
+		"2024-01-02-trip.md": "## 2024-01-02 Trip!\n\nsecond day\n```\n## not a heading\n```\n",
+		"2024-01-01.md":      "existing\n",
+	}
+	for name, body := range cases {
+		got, err := os.ReadFile(filepath.Join(root, "inbox", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != body {
+			t.Fatalf("%s: got %q want %q", name, got, body)
+		}
+	}
+	if _, err := ImportMarkdown(context.Background(), root, src, ImportOptions{Level: 4}); err == nil {
+		t.Fatal("expected error when no headings match")
+	}
+}
+
+func TestSlugifyTransliteratesAndTruncates(t *testing.T) {
+	cases := []struct {
+		in   string
+		opts SlugOptions
+		want string
+	}{
+		{"Café Crème", SlugOptions{}, "café-crème"},
+		{"Café Crème", SlugOptions{Transliterate: true}, "cafe-creme"},
+		{"Straße Ærø", SlugOptions{Transliterate: true}, "strasse-aero"},
+		{"Über lange Überschrift", SlugOptions{Transliterate: true, MaxLen: 11}, "uber-lange"},
+		{"日本語 ノート", SlugOptions{MaxLen: 3}, "日本語"},
+		{"!!!", SlugOptions{Transliterate: true}, "section"},
+	}
+	for _, tc := range cases {
+		if got := Slugify(tc.in, tc.opts); got != tc.want {
+			t.Fatalf("Slugify(%q, %+v)=%q want %q", tc.in, tc.opts, got, tc.want)
+		}
+	}
+}
+
+func TestImportMarkdownSuffixesCollidingSlugs(t *testing.T) {
+	root := t.TempDir()
+	src := filepath.Join(t.TempDir(), "notes.md")
+	if err := os.WriteFile(src, []byte("# Résumé\n\none\n\n# Resume\n\ntwo\n\n# résumé!\n\nthree\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	res, err := ImportMarkdown(context.Background(), root, src, ImportOptions{Level: 1, Slug: SlugOptions{Transliterate: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"inbox/resume.md", "inbox/resume-2.md", "inbox/resume-3.md"}
+	if !reflect.DeepEqual(res.Created, want) {
+		t.Fatalf("created=%v want %v", res.Created, want)
+	}
+	got, err := os.ReadFile(filepath.Join(root, "inbox", "resume.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "# Résumé\n\none\n" {
+		t.Fatalf("first section clobbered: %q", got)
+	}
+}
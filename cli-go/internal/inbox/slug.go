@@ -0,0 +1,59 @@
+package inbox
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+const defaultSlug = "section"
+
+var transliterations = map[rune]string{
+	'ß': "ss",
+	'æ': "ae",
+	'œ': "oe",
+	'ø': "o",
+	'đ': "d",
+	'ð': "d",
+	'þ': "th",
+	'ł': "l",
+	'ı': "i",
+}
+
+type SlugOptions struct {
+	MaxLen        int
+	Transliterate bool
+}
+
+func Slugify(s string, opts SlugOptions) string {
+	s = strings.ToLower(s)
+	if opts.Transliterate {
+		s = transliterate(s)
+	}
+	slug := strings.Trim(slugUnsafe.ReplaceAllString(s, "-"), "-")
+	if opts.MaxLen > 0 {
+		if r := []rune(slug); len(r) > opts.MaxLen {
+			slug = strings.TrimRight(string(r[:opts.MaxLen]), "-")
+		}
+	}
+	if slug == "" {
+		return defaultSlug
+	}
+	return slug
+}
+
+func transliterate(s string) string {
+	var sb strings.Builder
+	for _, r := range norm.NFD.String(s) {
+		if unicode.Is(unicode.Mn, r) {
+			continue
+		}
+		if t, ok := transliterations[r]; ok {
+			sb.WriteString(t)
+			continue
+		}
+		sb.WriteRune(r)
+	}
+	return norm.NFC.String(sb.String())
+}
@@ -0,0 +1,197 @@
+package mcpserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/jsonrpc"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+const (
+	methodInitialize = "initialize"
+	methodShutdown   = "shutdown"
+	methodExit       = "exit"
+)
+
+type lifecycleTransport struct {
+	mcp.Transport
+	idle     time.Duration
+	features map[string]bool
+}
+
+func (t lifecycleTransport) Connect(ctx context.Context) (mcp.Connection, error) {
+	conn, err := t.Transport.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &lifecycleConn{Connection: conn, idle: t.idle, features: t.features}, nil
+}
+
+type lifecycleConn struct {
+	mcp.Connection
+	idle         time.Duration
+	features     map[string]bool
+	shuttingDown atomic.Bool
+
+	mu           sync.Mutex
+	lastActivity time.Time
+	inFlight     map[jsonrpc.ID]bool
+	initID       *jsonrpc.ID
+}
+
+func (c *lifecycleConn) Read(ctx context.Context) (jsonrpc.Message, error) {
+	for {
+		msg, err := c.readWithIdle(ctx)
+		if err != nil {
+			return nil, err
+		}
+		req, ok := msg.(*jsonrpc.Request)
+		if !ok {
+			return msg, nil
+		}
+		// The SDK rejects methods outside the MCP spec before any middleware
+		// runs, so the LSP-style shutdown/exit pair is handled here instead.
+		switch {
+		case req.Method == methodExit:
+			return nil, io.EOF
+		case req.Method == methodShutdown:
+			c.shuttingDown.Store(true)
+			if err := c.reply(ctx, req, nil); err != nil {
+				return nil, err
+			}
+		case c.shuttingDown.Load():
+			if err := c.reply(ctx, req, &jsonrpc.Error{Code: jsonrpc.CodeInvalidRequest, Message: "server is shutting down"}); err != nil {
+				return nil, err
+			}
+		default:
+			c.track(req)
+			if req.Method == methodInitialize && req.IsCall() {
+				c.mu.Lock()
+				c.initID = &req.ID
+				c.mu.Unlock()
+			}
+			return msg, nil
+		}
+	}
+}
+
+func (c *lifecycleConn) Write(ctx context.Context, msg jsonrpc.Message) error {
+	if resp, ok := msg.(*jsonrpc.Response); ok && c.isInitResponse(resp) {
+		msg = c.withFeatures(resp)
+	}
+	err := c.Connection.Write(ctx, msg)
+	c.mu.Lock()
+	c.lastActivity = time.Now()
+	if resp, ok := msg.(*jsonrpc.Response); ok {
+		delete(c.inFlight, resp.ID)
+	}
+	c.mu.Unlock()
+	return err
+}
+
+func (c *lifecycleConn) isInitResponse(resp *jsonrpc.Response) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.initID == nil || *c.initID != resp.ID {
+		return false
+	}
+	c.initID = nil
+	return true
+}
+
+func (c *lifecycleConn) withFeatures(resp *jsonrpc.Response) *jsonrpc.Response {
+	// The SDK's Implementation type has no room for extra fields, so
+	// serverInfo.features is spliced into the encoded initialize result.
+	if c.features == nil || resp.Error != nil {
+		return resp
+	}
+	var result map[string]json.RawMessage
+	if err := json.Unmarshal(resp.Result, &result); err != nil {
+		return resp
+	}
+	var info map[string]any
+	if err := json.Unmarshal(result["serverInfo"], &info); err != nil || info == nil {
+		return resp
+	}
+	info["features"] = c.features
+	encoded, err := json.Marshal(info)
+	if err != nil {
+		return resp
+	}
+	result["serverInfo"] = encoded
+	body, err := json.Marshal(result)
+	if err != nil {
+		return resp
+	}
+	return &jsonrpc.Response{ID: resp.ID, Result: body}
+}
+
+func (c *lifecycleConn) track(req *jsonrpc.Request) {
+	if !req.IsCall() || c.idle <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.inFlight == nil {
+		c.inFlight = map[jsonrpc.ID]bool{}
+	}
+	c.inFlight[req.ID] = true
+}
+
+func (c *lifecycleConn) idleLeft() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// A session serving a call is never idle; check again a full timeout later.
+	if len(c.inFlight) > 0 {
+		return c.idle
+	}
+	return c.idle - time.Since(c.lastActivity)
+}
+
+func (c *lifecycleConn) readWithIdle(ctx context.Context) (jsonrpc.Message, error) {
+	if c.idle <= 0 {
+		return c.Connection.Read(ctx)
+	}
+	c.mu.Lock()
+	if c.lastActivity.IsZero() {
+		c.lastActivity = time.Now()
+	}
+	c.mu.Unlock()
+	for {
+		wait := c.idleLeft()
+		if wait <= 0 {
+			return nil, io.EOF
+		}
+		// Cancelling a read is safe: the transport keeps buffering incoming lines,
+		// so the next Read picks up anything that arrived meanwhile.
+		readCtx, cancel := context.WithTimeout(ctx, wait)
+		msg, err := c.Connection.Read(readCtx)
+		cancel()
+		if err == nil {
+			c.mu.Lock()
+			c.lastActivity = time.Now()
+			c.mu.Unlock()
+			return msg, nil
+		}
+		if ctx.Err() != nil || !errors.Is(err, context.DeadlineExceeded) {
+			return nil, err
+		}
+	}
+}
+
+func (c *lifecycleConn) reply(ctx context.Context, req *jsonrpc.Request, rerr *jsonrpc.Error) error {
+	if !req.IsCall() {
+		return nil
+	}
+	resp := &jsonrpc.Response{ID: req.ID, Result: json.RawMessage("null")}
+	if rerr != nil {
+		resp = &jsonrpc.Response{ID: req.ID, Error: rerr}
+	}
+	return c.Connection.Write(ctx, resp)
+}
@@ -0,0 +1,169 @@
+package mcpserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/jsonrpc"
+)
+
+func TestShutdownThenExitEndsRun(t *testing.T) {
+	in := strings.Join([]string{
+		`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-06-18","capabilities":{},"clientInfo":{"name":"test","version":"0"}}}`,
+		`{"jsonrpc":"2.0","method":"notifications/initialized","params":{}}`,
+		`{"jsonrpc":"2.0","id":2,"method":"shutdown"}`,
+		`{"jsonrpc":"2.0","id":3,"method":"tools/list","params":{}}`,
+		`{"jsonrpc":"2.0","method":"exit"}`,
+		`{"jsonrpc":"2.0","id":4,"method":"tools/list","params":{}}`,
+	}, "\n") + "\n"
+	var out bytes.Buffer
+	srv := NewWithIO(t.TempDir(), true, nil, strings.NewReader(in), &out)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := srv.Run(ctx); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if ctx.Err() != nil {
+		t.Fatal("run did not return before the timeout")
+	}
+
+	responses := map[float64]map[string]any{}
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		var msg map[string]any
+		if err := json.Unmarshal([]byte(line), &msg); err != nil {
+			t.Fatalf("bad output line %q: %v", line, err)
+		}
+		if id, ok := msg["id"].(float64); ok {
+			responses[id] = msg
+		}
+	}
+	if resp, ok := responses[2]; !ok || resp["error"] != nil {
+		t.Fatalf("shutdown should succeed: %v", resp)
+	}
+	if resp, ok := responses[3]; !ok || resp["error"] == nil {
+		t.Fatalf("calls after shutdown should be refused: %v", resp)
+	}
+	if _, ok := responses[4]; ok {
+		t.Fatal("messages after exit should not be handled")
+	}
+}
+
+func TestIdleTimeoutResetsOnPing(t *testing.T) {
+	inR, inW := io.Pipe()
+	var out lockedBuffer
+	srv := NewWithIO(t.TempDir(), true, nil, inR, &out)
+	srv.IdleTimeout = 300 * time.Millisecond
+	done := make(chan error, 1)
+	start := time.Now()
+	go func() { done <- srv.Run(context.Background()) }()
+
+	send := func(line string) {
+		if _, err := io.WriteString(inW, line+"\n"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	send(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-06-18","capabilities":{},"clientInfo":{"name":"test","version":"0"}}}`)
+	send(`{"jsonrpc":"2.0","method":"notifications/initialized","params":{}}`)
+	for i := 0; i < 3; i++ {
+		time.Sleep(200 * time.Millisecond)
+		send(fmt.Sprintf(`{"jsonrpc":"2.0","id":%d,"method":"ping"}`, 10+i))
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("run: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("run did not return after the idle timeout")
+	}
+	if elapsed := time.Since(start); elapsed < 900*time.Millisecond {
+		t.Fatalf("pings should have kept the session alive, returned after %v", elapsed)
+	}
+	if !strings.Contains(out.String(), `"id":12`) {
+		t.Fatalf("missing ping response:\n%s", out.String())
+	}
+	_ = inW.Close()
+}
+
+type queueConn struct {
+	incoming chan jsonrpc.Message
+}
+
+func (q *queueConn) Read(ctx context.Context) (jsonrpc.Message, error) {
+	select {
+	case msg := <-q.incoming:
+		return msg, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (q *queueConn) Write(context.Context, jsonrpc.Message) error { return nil }
+func (q *queueConn) Close() error                                 { return nil }
+func (q *queueConn) SessionID() string                            { return "" }
+
+func TestIdleTimeoutWaitsForInFlightCalls(t *testing.T) {
+	idle := 100 * time.Millisecond
+	q := &queueConn{incoming: make(chan jsonrpc.Message, 1)}
+	conn := &lifecycleConn{Connection: q, idle: idle}
+	id, err := jsonrpc.MakeID(float64(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.incoming <- &jsonrpc.Request{ID: id, Method: "tools/call"}
+	if _, err := conn.Read(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// The call takes several idle periods; the session must survive it.
+	done := make(chan error, 1)
+	go func() {
+		_, err := conn.Read(context.Background())
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		t.Fatalf("session expired while a call was in flight: %v", err)
+	case <-time.After(4 * idle):
+	}
+
+	if err := conn.Write(context.Background(), &jsonrpc.Response{ID: id, Result: json.RawMessage("{}")}); err != nil {
+		t.Fatal(err)
+	}
+	responded := time.Now()
+	select {
+	case err := <-done:
+		if err != io.EOF {
+			t.Fatalf("expected EOF after idling, got %v", err)
+		}
+		if waited := time.Since(responded); waited < idle/2 {
+			t.Fatalf("idle timer did not restart on the response, expired after %v", waited)
+		}
+	case <-time.After(10 * idle):
+		t.Fatal("session never expired after the call finished")
+	}
+}
+
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
@@ -0,0 +1,73 @@
+package mcpserver
+
+import (
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultPageSize   = 200
+	maxPageSize       = 2000
+	maxCachedFiles    = 8
+	pageCacheMaxBytes = 64 * 1024 * 1024
+)
+
+type cachedLines struct {
+	mtime time.Time
+	size  int64
+	lines []string
+}
+
+type lineCache struct {
+	mu    sync.Mutex
+	order []string
+	files map[string]cachedLines
+}
+
+func newLineCache() *lineCache {
+	return &lineCache{files: map[string]cachedLines{}}
+}
+
+func (c *lineCache) lines(path string) ([]string, error) {
+	st, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	if hit, ok := c.files[path]; ok && hit.mtime.Equal(st.ModTime()) && hit.size == st.Size() {
+		c.touch(path)
+		c.mu.Unlock()
+		return hit.lines, nil
+	}
+	c.mu.Unlock()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	lines := strings.Split(string(data), "\n")
+	if st.Size() > pageCacheMaxBytes {
+		return lines, nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.files[path] = cachedLines{mtime: st.ModTime(), size: st.Size(), lines: lines}
+	c.touch(path)
+	for len(c.order) > maxCachedFiles {
+		delete(c.files, c.order[0])
+		c.order = c.order[1:]
+	}
+	return lines, nil
+}
+
+func (c *lineCache) touch(path string) {
+	for i, p := range c.order {
+		if p == path {
+			c.order = append(c.order[:i], c.order[i+1:]...)
+			break
+		}
+	}
+	c.order = append(c.order, path)
+}
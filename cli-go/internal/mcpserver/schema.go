@@ -0,0 +1,77 @@
+package mcpserver
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/google/jsonschema-go/jsonschema"
+)
+
+func inputSchema[T any]() *jsonschema.Schema {
+	s, err := jsonschema.For[T](nil)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+func rawDefault(v any) json.RawMessage {
+	b, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func nonNullInteger(p *jsonschema.Schema) {
+	// Limits are pointers only to tell an omitted value from 0; null is not a valid input.
+	p.Types = nil
+	p.Type = "integer"
+}
+
+func (s *Server) searchInputSchema() *jsonschema.Schema {
+	schema := inputSchema[searchArgs]()
+	p := schema.Properties
+	p["limit"].Default = json.RawMessage(strconv.Itoa(defaultSearchLimit))
+	nonNullInteger(p["limit"])
+	p["context"].Default = json.RawMessage("0")
+	// Offer the configured search paths as picker values; an omitted list searches all of them.
+	if len(s.Paths) > 0 {
+		groups := make([]any, len(s.Paths))
+		for i, g := range s.Paths {
+			groups[i] = g
+		}
+		p["paths"].Items.Enum = groups
+		p["paths"].Default = rawDefault(s.Paths)
+	}
+	return schema
+}
+
+func readFileInputSchema() *jsonschema.Schema {
+	schema := inputSchema[readFileArgs]()
+	p := schema.Properties
+	p["start_line"].Default = json.RawMessage("1")
+	p["end_line"].Default = json.RawMessage("0")
+	p["page_size"].Default = json.RawMessage(strconv.Itoa(defaultPageSize))
+	p["strip_front_matter"].Default = json.RawMessage("false")
+	return schema
+}
+
+func recentInputSchema() *jsonschema.Schema {
+	schema := inputSchema[recentArgs]()
+	schema.Properties["limit"].Default = json.RawMessage(strconv.Itoa(defaultRecentLimit))
+	nonNullInteger(schema.Properties["limit"])
+	return schema
+}
+
+func appendInputSchema() *jsonschema.Schema {
+	schema := inputSchema[appendArgs]()
+	schema.Properties["ensure_newline"].Default = json.RawMessage("true")
+	return schema
+}
+
+func (s *Server) createReminderInputSchema() *jsonschema.Schema {
+	schema := inputSchema[createReminderArgs]()
+	schema.Properties["path"].Default = rawDefault(s.reminderPath())
+	return schema
+}
@@ -1,6 +1,7 @@
 package mcpserver
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
@@ -14,23 +15,45 @@ import (
 
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 
+	"margin/internal/config"
+	"margin/internal/frontmatter"
+	"margin/internal/remind"
 	"margin/internal/rootio"
 	"margin/internal/search"
 )
 
 const (
-	serverVersion      = "0.1.0"
-	defaultSearchLimit = 20
-	defaultRecentLimit = 20
-	maxToolLimit       = 500
+	serverVersion        = "0.1.0"
+	defaultSearchLimit   = 20
+	defaultRecentLimit   = 20
+	maxToolLimit         = 500
+	recentPreviewBytes   = 8 * 1024
+	recentPreviewLimit   = 180
+	maxContextLines      = 20
+	defaultMaxResultSize = 256 * 1024
+	rootLockTimeout      = 5 * time.Second
 )
 
 type Server struct {
-	Root     string
-	Readonly bool
-	Paths    []string
-	in       io.Reader
-	out      io.Writer
+	Root                   string
+	Readonly               bool
+	Paths                  []string
+	Hidden                 bool
+	ExcludeHistory         bool
+	IncludeBinary          bool
+	ReminderPath           string
+	DefaultReminderMessage string
+	DedupeReminders        bool
+	MinQueryLength         int
+	MaxResults             int
+	AppendPaths            []string
+	Features               map[string]bool
+	IdleTimeout            time.Duration
+	MaxResultBytes         int
+	SearchCache            *search.Cache
+	in                     io.Reader
+	out                    io.Writer
+	pages                  *lineCache
 }
 
 type RecentItem struct {
@@ -40,30 +63,55 @@ type RecentItem struct {
 }
 
 type searchArgs struct {
-	Query string   `json:"query"`
-	Limit int      `json:"limit,omitempty"`
-	Paths []string `json:"paths,omitempty"`
+	Query   string   `json:"query" jsonschema:"text to search for (case-insensitive)"`
+	Limit   *int     `json:"limit,omitempty" jsonschema:"maximum number of results; 0 = unlimited (still subject to the response size cap)"`
+	Paths   []string `json:"paths,omitempty" jsonschema:"path groups to search; defaults to the configured search paths"`
+	Context int      `json:"context,omitempty" jsonschema:"lines of context before and after each hit (max 20)"`
 }
 
 type readFileArgs struct {
-	Path      string `json:"path"`
-	StartLine int    `json:"start_line,omitempty"`
-	EndLine   int    `json:"end_line,omitempty"`
+	Path             string `json:"path" jsonschema:"file path relative to the margin root"`
+	StartLine        int    `json:"start_line,omitempty" jsonschema:"first line to return (1-based)"`
+	EndLine          int    `json:"end_line,omitempty" jsonschema:"last line to return, inclusive; 0 reads to the end"`
+	Page             int    `json:"page,omitempty" jsonschema:"page number (1-based); overrides start_line/end_line"`
+	PageSize         int    `json:"page_size,omitempty" jsonschema:"lines per page when page is set"`
+	StripFrontMatter bool   `json:"strip_front_matter,omitempty" jsonschema:"return YAML front matter separately from content"`
 }
 
 type recentArgs struct {
-	Limit int    `json:"limit,omitempty"`
-	Since string `json:"since,omitempty"`
+	Limit *int   `json:"limit,omitempty" jsonschema:"maximum number of files; 0 = unlimited (still subject to the response size cap)"`
+	Since string `json:"since,omitempty" jsonschema:"only files modified at or after this RFC3339 time"`
 }
 
 type appendArgs struct {
-	Path    string `json:"path,omitempty"`
-	Content string `json:"content"`
+	Path          string `json:"path,omitempty" jsonschema:"note to append to, relative to the root; defaults to a new timestamped inbox note"`
+	Content       string `json:"content" jsonschema:"text to append"`
+	EnsureNewline *bool  `json:"ensure_newline,omitempty" jsonschema:"start on a new line if the file does not end with one"`
+}
+
+type createReminderArgs struct {
+	When    string `json:"when" jsonschema:"due time as YYYY-MM-DD or YYYY-MM-DD HH:MM (local time)"`
+	Message string `json:"message" jsonschema:"reminder text"`
+	Path    string `json:"path,omitempty" jsonschema:"note to append the REMIND line to, relative to the root"`
+}
+
+type searchOutput struct {
+	Results   []search.Result `json:"results"`
+	Truncated bool            `json:"truncated,omitempty"`
+}
+
+type recentOutput struct {
+	Items     []RecentItem `json:"items"`
+	Truncated bool         `json:"truncated,omitempty"`
 }
 
 type readFileOutput struct {
-	Path    string `json:"path"`
-	Content string `json:"content"`
+	Path        string         `json:"path"`
+	Content     string         `json:"content"`
+	TotalLines  int            `json:"total_lines,omitempty"`
+	HasMore     bool           `json:"has_more,omitempty"`
+	NextPage    int            `json:"next_page,omitempty"`
+	FrontMatter map[string]any `json:"front_matter,omitempty"`
 }
 
 type appendOutput struct {
@@ -82,6 +130,7 @@ func NewWithIO(root string, readonly bool, paths []string, in io.Reader, out io.
 		Paths:    paths,
 		in:       in,
 		out:      out,
+		pages:    newLineCache(),
 	}
 }
 
@@ -89,22 +138,76 @@ func (s *Server) Run(ctx context.Context) error {
 	if err := ctx.Err(); err != nil {
 		return err
 	}
+	srv := s.newMCPServer()
+
+	in := s.in
+	if in == nil {
+		in = os.Stdin
+	}
+	out := s.out
+	if out == nil {
+		out = os.Stdout
+	}
+	transport := &mcp.IOTransport{
+		Reader: io.NopCloser(in),
+		Writer: nopWriteCloser{Writer: out},
+	}
+	return srv.Run(ctx, lifecycleTransport{Transport: transport, idle: s.IdleTimeout, features: s.Features})
+}
+
+func (s *Server) ListTools(ctx context.Context) ([]*mcp.Tool, error) {
+	cs, closeFn, err := s.connectInMemory(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer closeFn()
+	res, err := cs.ListTools(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	return res.Tools, nil
+}
+
+func (s *Server) connectInMemory(ctx context.Context) (*mcp.ClientSession, func(), error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+	clientTransport, serverTransport := mcp.NewInMemoryTransports()
+	ss, err := s.newMCPServer().Connect(ctx, serverTransport, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	client := mcp.NewClient(&mcp.Implementation{Name: "margin-tools", Version: serverVersion}, nil)
+	cs, err := client.Connect(ctx, clientTransport, nil)
+	if err != nil {
+		_ = ss.Close()
+		return nil, nil, err
+	}
+	return cs, func() {
+		_ = cs.Close()
+		_ = ss.Close()
+	}, nil
+}
+
+func (s *Server) newMCPServer() *mcp.Server {
 	srv := mcp.NewServer(&mcp.Implementation{Name: "margin", Version: serverVersion}, nil)
 
 	mcp.AddTool(srv, &mcp.Tool{
 		Name:        "search",
-		Description: "Search notes",
-	}, func(ctx context.Context, _ *mcp.CallToolRequest, input searchArgs) (*mcp.CallToolResult, []search.Result, error) {
+		Description: "Search notes; set context to include up to 20 surrounding lines per hit",
+		InputSchema: s.searchInputSchema(),
+	}, func(ctx context.Context, _ *mcp.CallToolRequest, input searchArgs) (*mcp.CallToolResult, searchOutput, error) {
 		res, err := s.searchTool(ctx, input)
 		if err != nil {
-			return nil, nil, err
+			return nil, searchOutput{}, err
 		}
-		return nil, res, nil
+		return nil, capSearchOutput(res, s.maxResultBytes()), nil
 	})
 
 	mcp.AddTool(srv, &mcp.Tool{
 		Name:        "read_file",
-		Description: "Read file under margin root",
+		Description: "Read file under margin root; use start_line/end_line or page/page_size (lines) to read a slice; strip_front_matter returns YAML front matter separately",
+		InputSchema: readFileInputSchema(),
 	}, func(ctx context.Context, _ *mcp.CallToolRequest, input readFileArgs) (*mcp.CallToolResult, readFileOutput, error) {
 		res, err := s.readFileTool(ctx, input)
 		if err != nil {
@@ -116,10 +219,11 @@ func (s *Server) Run(ctx context.Context) error {
 	mcp.AddTool(srv, &mcp.Tool{
 		Name:        "recent",
 		Description: "List recent files",
-	}, func(ctx context.Context, _ *mcp.CallToolRequest, input recentArgs) (*mcp.CallToolResult, []RecentItem, error) {
+		InputSchema: recentInputSchema(),
+	}, func(ctx context.Context, _ *mcp.CallToolRequest, input recentArgs) (*mcp.CallToolResult, recentOutput, error) {
 		res, err := s.recentTool(ctx, input)
 		if err != nil {
-			return nil, nil, err
+			return nil, recentOutput{}, err
 		}
 		return nil, res, nil
 	})
@@ -128,6 +232,7 @@ func (s *Server) Run(ctx context.Context) error {
 		mcp.AddTool(srv, &mcp.Tool{
 			Name:        "append",
 			Description: "Append text under scratch/inbox/slack",
+			InputSchema: appendInputSchema(),
 		}, func(ctx context.Context, _ *mcp.CallToolRequest, input appendArgs) (*mcp.CallToolResult, appendOutput, error) {
 			res, err := s.appendTool(ctx, input)
 			if err != nil {
@@ -135,21 +240,20 @@ func (s *Server) Run(ctx context.Context) error {
 			}
 			return nil, res, nil
 		})
-	}
 
-	in := s.in
-	if in == nil {
-		in = os.Stdin
-	}
-	out := s.out
-	if out == nil {
-		out = os.Stdout
-	}
-	transport := &mcp.IOTransport{
-		Reader: io.NopCloser(in),
-		Writer: nopWriteCloser{Writer: out},
+		mcp.AddTool(srv, &mcp.Tool{
+			Name:        "create_reminder",
+			Description: "Append a REMIND[when] line to a note and register it (when: YYYY-MM-DD or YYYY-MM-DD HH:MM)",
+			InputSchema: s.createReminderInputSchema(),
+		}, func(ctx context.Context, _ *mcp.CallToolRequest, input createReminderArgs) (*mcp.CallToolResult, remind.Entry, error) {
+			res, err := s.createReminderTool(ctx, input)
+			if err != nil {
+				return nil, remind.Entry{}, err
+			}
+			return nil, res, nil
+		})
 	}
-	return srv.Run(ctx, transport)
+	return srv
 }
 
 func (s *Server) searchTool(ctx context.Context, args searchArgs) ([]search.Result, error) {
@@ -159,12 +263,67 @@ func (s *Server) searchTool(ctx context.Context, args searchArgs) ([]search.Resu
 	if strings.TrimSpace(args.Query) == "" {
 		return nil, errors.New("query is required")
 	}
-	limit := clampedLimit(float64(args.Limit), defaultSearchLimit)
+	limit := toolLimit(args.Limit, defaultSearchLimit)
 	paths := args.Paths
 	if len(paths) == 0 {
 		paths = s.Paths
 	}
-	return search.Run(ctx, s.Root, args.Query, paths, limit)
+	opts := search.Options{
+		Hidden:         s.Hidden,
+		ExcludeHistory: s.ExcludeHistory,
+		IncludeBinary:  s.IncludeBinary,
+		ContextLines:   min(max(args.Context, 0), maxContextLines),
+		MinQueryLength: s.MinQueryLength,
+		MaxResults:     s.MaxResults,
+	}
+	return s.SearchCache.Run(ctx, s.Root, args.Query, paths, limit, opts)
+}
+
+func (s *Server) maxResultBytes() int {
+	if s.MaxResultBytes > 0 {
+		return s.MaxResultBytes
+	}
+	return defaultMaxResultSize
+}
+
+func capSearchOutput(results []search.Result, maxBytes int) searchOutput {
+	total := 0
+	for i, r := range results {
+		b, err := json.Marshal(r)
+		if err != nil {
+			continue
+		}
+		total += len(b)
+		if total > maxBytes {
+			if i == 0 {
+				return searchOutput{Results: []search.Result{fitSearchResult(r, maxBytes)}, Truncated: true}
+			}
+			return searchOutput{Results: results[:i], Truncated: true}
+		}
+	}
+	return searchOutput{Results: results}
+}
+
+func fitSearchResult(r search.Result, maxBytes int) search.Result {
+	// A single result larger than the whole cap loses its context first, then
+	// as much of its preview as it takes to fit.
+	r.Before, r.After = nil, nil
+	for r.Preview != "" {
+		b, err := json.Marshal(r)
+		if err != nil {
+			return r
+		}
+		over := len(b) - maxBytes
+		if over <= 0 {
+			break
+		}
+		if over >= len(r.Preview) {
+			r.Preview = ""
+			break
+		}
+		r.Preview = search.TruncatePreview(r.Preview, len(r.Preview)-over)
+	}
+	return r
 }
 
 func (s *Server) readFileTool(ctx context.Context, args readFileArgs) (readFileOutput, error) {
@@ -178,11 +337,18 @@ func (s *Server) readFileTool(ctx context.Context, args readFileArgs) (readFileO
 	if err != nil {
 		return readFileOutput{}, err
 	}
+	if args.Page > 0 {
+		return s.readFilePage(abs, args)
+	}
 	data, err := os.ReadFile(abs)
 	if err != nil {
 		return readFileOutput{}, err
 	}
 	content := string(data)
+	var meta map[string]any
+	if args.StripFrontMatter {
+		meta, content, _ = frontmatter.Split(content)
+	}
 	start, end := args.StartLine, args.EndLine
 	if start > 0 || end > 0 {
 		lines := strings.Split(content, "\n")
@@ -198,14 +364,45 @@ func (s *Server) readFileTool(ctx context.Context, args readFileArgs) (readFileO
 			content = ""
 		}
 	}
-	return readFileOutput{Path: filepath.ToSlash(args.Path), Content: content}, nil
+	return readFileOutput{Path: filepath.ToSlash(args.Path), Content: content, FrontMatter: meta}, nil
+}
+
+func (s *Server) readFilePage(abs string, args readFileArgs) (readFileOutput, error) {
+	lines, err := s.pages.lines(abs)
+	if err != nil {
+		return readFileOutput{}, err
+	}
+	var meta map[string]any
+	if args.StripFrontMatter {
+		var body string
+		if meta, body, _ = frontmatter.Split(strings.Join(lines, "\n")); meta != nil {
+			lines = strings.Split(body, "\n")
+		}
+	}
+	size := args.PageSize
+	if size <= 0 {
+		size = defaultPageSize
+	}
+	size = min(size, maxPageSize)
+	out := readFileOutput{Path: filepath.ToSlash(args.Path), TotalLines: len(lines), FrontMatter: meta}
+	start := (args.Page - 1) * size
+	if start >= len(lines) {
+		return out, nil
+	}
+	end := min(len(lines), start+size)
+	out.Content = strings.Join(lines[start:end], "\n")
+	if end < len(lines) {
+		out.HasMore = true
+		out.NextPage = args.Page + 1
+	}
+	return out, nil
 }
 
-func (s *Server) recentTool(ctx context.Context, args recentArgs) ([]RecentItem, error) {
+func (s *Server) recentTool(ctx context.Context, args recentArgs) (recentOutput, error) {
 	if err := ctx.Err(); err != nil {
-		return nil, err
+		return recentOutput{}, err
 	}
-	limit := clampedLimit(float64(args.Limit), defaultRecentLimit)
+	limit := toolLimit(args.Limit, defaultRecentLimit)
 	var since time.Time
 	if args.Since != "" {
 		t, err := time.Parse(time.RFC3339, args.Since)
@@ -213,14 +410,18 @@ func (s *Server) recentTool(ctx context.Context, args recentArgs) ([]RecentItem,
 			since = t
 		}
 	}
-	files, err := rootio.ListFilesRecursive(rootio.ResolvePathGroups(s.Root, s.Paths))
+	paths := rootio.ResolvePathGroups(s.Root, s.Paths)
+	if s.ExcludeHistory {
+		paths = rootio.WithoutHistory(paths)
+	}
+	files, err := rootio.ListFilesRecursive(paths, rootio.WalkOptions{Hidden: s.Hidden})
 	if err != nil {
-		return nil, err
+		return recentOutput{}, err
 	}
 	items := make([]RecentItem, 0, len(files))
 	for _, f := range files {
 		if err := ctx.Err(); err != nil {
-			return nil, err
+			return recentOutput{}, err
 		}
 		st, err := os.Stat(f)
 		if err != nil {
@@ -229,19 +430,45 @@ func (s *Server) recentTool(ctx context.Context, args recentArgs) ([]RecentItem,
 		if !since.IsZero() && st.ModTime().Before(since) {
 			continue
 		}
-		data, _ := os.ReadFile(f)
-		preview := strings.TrimSpace(firstLine(string(data)))
-		if len(preview) > 180 {
-			preview = preview[:180]
-		}
 		rel, _ := rootio.RelUnderRoot(s.Root, f)
-		items = append(items, RecentItem{Path: rel, Mtime: st.ModTime().Format(time.RFC3339), Preview: preview})
+		items = append(items, RecentItem{Path: rel, Mtime: st.ModTime().Format(time.RFC3339)})
 	}
 	sortByMtimeDesc(items)
-	if len(items) > limit {
+	if limit > 0 && len(items) > limit {
 		items = items[:limit]
 	}
-	return items, nil
+	// Previews are read while measuring, so an unlimited listing stops reading
+	// files once the response size cap is reached.
+	maxBytes, total := s.maxResultBytes(), 0
+	for i := range items {
+		items[i].Preview = s.recentPreview(filepath.Join(s.Root, filepath.FromSlash(items[i].Path)))
+		b, err := json.Marshal(items[i])
+		if err != nil {
+			continue
+		}
+		total += len(b)
+		if total > maxBytes {
+			if i == 0 {
+				items[0].Preview = ""
+				return recentOutput{Items: items[:1], Truncated: true}, nil
+			}
+			return recentOutput{Items: items[:i], Truncated: true}, nil
+		}
+	}
+	return recentOutput{Items: items}, nil
+}
+
+func (s *Server) recentPreview(path string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer func() { _ = f.Close() }()
+	data, _ := io.ReadAll(io.LimitReader(f, recentPreviewBytes))
+	if !s.IncludeBinary && rootio.IsBinaryData(data) {
+		return ""
+	}
+	return search.TruncatePreview(strings.TrimSpace(firstLine(string(data))), recentPreviewLimit)
 }
 
 func (s *Server) appendTool(ctx context.Context, args appendArgs) (appendOutput, error) {
@@ -251,8 +478,18 @@ func (s *Server) appendTool(ctx context.Context, args appendArgs) (appendOutput,
 	if s.Readonly {
 		return appendOutput{}, errors.New("readonly mode")
 	}
+	lock, err := rootio.Lock(s.Root, rootLockTimeout)
+	if err != nil {
+		return appendOutput{}, err
+	}
+	defer func() { _ = lock.Release() }()
+	out, _, err := s.appendFile(args, false)
+	return out, err
+}
+
+func (s *Server) appendFile(args appendArgs, countLines bool) (appendOutput, int, error) {
 	if strings.TrimSpace(args.Content) == "" {
-		return appendOutput{}, errors.New("content is required")
+		return appendOutput{}, 0, errors.New("content is required")
 	}
 	p := args.Path
 	if p == "" {
@@ -260,27 +497,157 @@ func (s *Server) appendTool(ctx context.Context, args appendArgs) (appendOutput,
 	}
 	abs, err := s.safeAppendPath(p)
 	if err != nil {
-		return appendOutput{}, err
+		return appendOutput{}, 0, err
 	}
 	if err := os.MkdirAll(filepath.Dir(abs), 0o755); err != nil {
-		return appendOutput{}, err
+		return appendOutput{}, 0, err
+	}
+	lines := 0
+	if countLines {
+		if lines, err = countNewlines(abs); err != nil {
+			return appendOutput{}, 0, err
+		}
+	}
+	content := args.Content
+	if args.EnsureNewline == nil || *args.EnsureNewline {
+		needs, err := needsLeadingNewline(abs, content)
+		if err != nil {
+			return appendOutput{}, 0, err
+		}
+		if needs {
+			content = "\n" + content
+		}
 	}
 	fh, err := os.OpenFile(abs, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
 	if err != nil {
-		return appendOutput{}, err
+		return appendOutput{}, 0, err
 	}
-	if _, err := fh.WriteString(args.Content); err != nil {
+	if _, err := fh.WriteString(content); err != nil {
 		_ = fh.Close()
-		return appendOutput{}, err
+		return appendOutput{}, 0, err
 	}
 	if err := fh.Close(); err != nil {
-		return appendOutput{}, err
+		return appendOutput{}, 0, err
 	}
+	// The first non-blank line of the appended text, counted from the lines already in the file.
+	leading := content[:len(content)-len(strings.TrimLeft(content, "\r\n"))]
+	line := lines + 1 + strings.Count(leading, "\n")
 	rel, _ := rootio.RelUnderRoot(s.Root, abs)
-	return appendOutput{Path: rel, Appended: len(args.Content)}, nil
+	return appendOutput{Path: rel, Appended: len(content)}, line, nil
+}
+
+func (s *Server) createReminderTool(ctx context.Context, args createReminderArgs) (remind.Entry, error) {
+	if err := ctx.Err(); err != nil {
+		return remind.Entry{}, err
+	}
+	if s.Readonly {
+		return remind.Entry{}, errors.New("readonly mode")
+	}
+	lock, err := rootio.Lock(s.Root, rootLockTimeout)
+	if err != nil {
+		return remind.Entry{}, err
+	}
+	defer func() { _ = lock.Release() }()
+	when := strings.TrimSpace(args.When)
+	if _, err := remind.ParseWhen(when); err != nil {
+		return remind.Entry{}, fmt.Errorf("invalid when %q: expected YYYY-MM-DD or YYYY-MM-DD HH:MM", args.When)
+	}
+	msg := strings.Join(strings.Fields(args.Message), " ")
+	if msg == "" {
+		return remind.Entry{}, errors.New("message is required")
+	}
+	p := args.Path
+	if p == "" {
+		p = s.reminderPath()
+	}
+	out, line, err := s.appendFile(appendArgs{Path: p, Content: fmt.Sprintf("REMIND[%s] %s\n", when, msg)}, true)
+	if err != nil {
+		return remind.Entry{}, err
+	}
+	if _, err := remind.Scan(ctx, s.Root, remind.ScanOptions{Hidden: s.Hidden, DedupeByMessage: s.DedupeReminders, DefaultMessage: s.DefaultReminderMessage}); err != nil {
+		return remind.Entry{}, err
+	}
+	entries, err := remind.LoadEntries(s.Root)
+	if err != nil {
+		return remind.Entry{}, err
+	}
+	for _, e := range entries {
+		if e.SourcePath == out.Path && e.SourceLine == line {
+			return e, nil
+		}
+	}
+	if s.DedupeReminders {
+		// The new line was suppressed as a duplicate; report the reminder that stands for it.
+		at, _ := remind.ParseWhen(when)
+		for _, e := range entries {
+			if e.When == at.Format(time.RFC3339) && e.Message == msg {
+				return e, nil
+			}
+		}
+	}
+	return remind.Entry{}, errors.New("reminder appended but not found in store")
+}
+
+func (s *Server) reminderPath() string {
+	if s.ReminderPath != "" {
+		return s.ReminderPath
+	}
+	return config.Default().MCPReminderPath
+}
+
+func needsLeadingNewline(path, content string) (bool, error) {
+	if strings.HasPrefix(content, "\n") || strings.HasPrefix(content, "\r\n") {
+		return false, nil
+	}
+	fh, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	defer func() { _ = fh.Close() }()
+	st, err := fh.Stat()
+	if err != nil {
+		return false, err
+	}
+	if st.Size() == 0 {
+		return false, nil
+	}
+	tail := make([]byte, 1)
+	if _, err := fh.ReadAt(tail, st.Size()-1); err != nil {
+		return false, err
+	}
+	return tail[0] != '\n', nil
+}
+
+func countNewlines(path string) (int, error) {
+	fh, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	defer func() { _ = fh.Close() }()
+	n := 0
+	buf := make([]byte, 32*1024)
+	for {
+		k, err := fh.Read(buf)
+		n += bytes.Count(buf[:k], []byte("\n"))
+		if err == io.EOF {
+			return n, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
 }
 
 func (s *Server) safePath(rel string) (string, error) {
+	if isAbsLike(rel) {
+		return "", fmt.Errorf("absolute paths are not allowed")
+	}
 	clean := filepath.Clean(filepath.FromSlash(rel))
 	abs := filepath.Join(s.Root, clean)
 	_, err := rootio.RelUnderRoot(s.Root, abs)
@@ -290,18 +657,63 @@ func (s *Server) safePath(rel string) (string, error) {
 	return abs, nil
 }
 
+func isAbsLike(p string) bool {
+	if filepath.IsAbs(p) || filepath.VolumeName(p) != "" {
+		return true
+	}
+	if strings.HasPrefix(p, "/") || strings.HasPrefix(p, `\`) {
+		return true
+	}
+	return len(p) >= 2 && p[1] == ':' && ((p[0] >= 'a' && p[0] <= 'z') || (p[0] >= 'A' && p[0] <= 'Z'))
+}
+
 func (s *Server) safeAppendPath(rel string) (string, error) {
 	abs, err := s.safePath(rel)
 	if err != nil {
 		return "", err
 	}
 	clean := filepath.ToSlash(filepath.Clean(filepath.FromSlash(rel)))
-	if !strings.HasPrefix(clean, "scratch/") &&
-		!strings.HasPrefix(clean, "inbox/") &&
-		!strings.HasPrefix(clean, "slack/") {
-		return "", fmt.Errorf("append path must be under scratch/, inbox/, or slack/")
+	prefixes := s.AppendPaths
+	if len(prefixes) == 0 {
+		prefixes = defaultAppendPaths
 	}
-	return abs, nil
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(clean, prefix) {
+			return abs, nil
+		}
+	}
+	return "", fmt.Errorf("append path must be under %s", strings.Join(prefixes, ", "))
+}
+
+var defaultAppendPaths = []string{"scratch/", "inbox/", "slack/"}
+
+func NormalizeAppendPaths(paths []string) ([]string, error) {
+	out := make([]string, 0, len(paths))
+	for _, p := range paths {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		if isAbsLike(p) {
+			return nil, fmt.Errorf("append path %q must be relative", p)
+		}
+		clean := filepath.ToSlash(filepath.Clean(filepath.FromSlash(p)))
+		if clean == "." || clean == ".." || strings.HasPrefix(clean, "../") {
+			return nil, fmt.Errorf("append path %q must be under root", p)
+		}
+		out = append(out, clean+"/")
+	}
+	return out, nil
+}
+
+func toolLimit(limit *int, def int) int {
+	switch {
+	case limit == nil:
+		return def
+	case *limit == 0:
+		return 0
+	}
+	return clampedLimit(float64(*limit), def)
 }
 
 func clampedLimit(v any, def int) int {
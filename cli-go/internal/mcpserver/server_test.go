@@ -1,10 +1,21 @@
 package mcpserver
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
+	"unicode/utf8"
+
+	"margin/internal/config"
+	"margin/internal/remind"
+	"margin/internal/rootio"
+	"margin/internal/search"
 )
 
 func TestSafeAppendPathRestrictsTargets(t *testing.T) {
@@ -17,6 +28,102 @@ func TestSafeAppendPathRestrictsTargets(t *testing.T) {
 	}
 }
 
+func TestSafeAppendPathHonorsConfiguredPrefixes(t *testing.T) {
+	srv := NewWithIO(t.TempDir(), false, nil, nil, nil)
+	paths, err := NormalizeAppendPaths([]string{" inbox ", "projects/notes/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.AppendPaths = paths
+	if _, err := srv.safeAppendPath("inbox/a.md"); err != nil {
+		t.Fatalf("expected inbox to be allowed: %v", err)
+	}
+	if _, err := srv.safeAppendPath("projects/notes/a.md"); err != nil {
+		t.Fatalf("expected configured prefix to be allowed: %v", err)
+	}
+	if _, err := srv.safeAppendPath("scratch/current/a.md"); err == nil {
+		t.Fatal("expected scratch to be rejected when not configured")
+	}
+	if _, err := srv.safeAppendPath("inboxes/a.md"); err == nil {
+		t.Fatal("expected prefix match to respect directory boundaries")
+	}
+	for _, bad := range []string{"/etc", "../outside", "."} {
+		if _, err := NormalizeAppendPaths([]string{bad}); err == nil {
+			t.Fatalf("expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestRecentAndSearchExcludeHistory(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{"scratch/current", "scratch/history/2026-01-01"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(root, dir, "note.md"), []byte("needle\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	srv := NewWithIO(root, true, []string{"scratch"}, nil, nil)
+	srv.ExcludeHistory = true
+	out, err := srv.recentTool(context.Background(), recentArgs{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	items := out.Items
+	if len(items) != 1 || items[0].Path != "scratch/current/note.md" {
+		t.Fatalf("unexpected recent items: %+v", items)
+	}
+	results, err := srv.searchTool(context.Background(), searchArgs{Query: "needle"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].File != "scratch/current/note.md" {
+		t.Fatalf("unexpected search results: %+v", results)
+	}
+}
+
+func TestRecentToolPreviewsLargeFiles(t *testing.T) {
+	root := t.TempDir()
+	inbox := filepath.Join(root, "inbox")
+	if err := os.MkdirAll(inbox, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	big := "  headline  \n" + strings.Repeat("x", 4*recentPreviewBytes)
+	if err := os.WriteFile(filepath.Join(inbox, "big.md"), []byte(big), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	srv := NewWithIO(root, true, []string{"inbox"}, nil, nil)
+	out, err := srv.recentTool(context.Background(), recentArgs{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	items := out.Items
+	if len(items) != 1 || items[0].Path != "inbox/big.md" || items[0].Preview != "headline" {
+		t.Fatalf("unexpected items: %+v", items)
+	}
+}
+
+func TestRecentPreviewTruncatesOnRuneBoundary(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "inbox"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	line := strings.Repeat("x", recentPreviewLimit-1) + "日本"
+	if err := os.WriteFile(filepath.Join(root, "inbox", "jp.md"), []byte(line), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	srv := NewWithIO(root, true, []string{"inbox"}, nil, nil)
+	out, err := srv.recentTool(context.Background(), recentArgs{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	items := out.Items
+	if len(items) != 1 || !utf8.ValidString(items[0].Preview) || items[0].Preview != strings.Repeat("x", recentPreviewLimit-1) {
+		t.Fatalf("unexpected preview: %+v", items)
+	}
+}
+
 func TestSearchToolRequiresQuery(t *testing.T) {
 	srv := NewWithIO(t.TempDir(), true, []string{"inbox"}, nil, nil)
 	_, err := srv.searchTool(context.Background(), searchArgs{})
@@ -37,6 +144,49 @@ func TestClampedLimit(t *testing.T) {
 	}
 }
 
+func TestToolLimitZeroIsUnlimited(t *testing.T) {
+	root := t.TempDir()
+	inbox := filepath.Join(root, "inbox")
+	if err := os.MkdirAll(inbox, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < defaultSearchLimit+5; i++ {
+		if err := os.WriteFile(filepath.Join(inbox, fmt.Sprintf("n%02d.md", i)), []byte("needle\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	srv := NewWithIO(root, true, []string{"inbox"}, nil, nil)
+	zero, negative := 0, -1
+	results, err := srv.searchTool(context.Background(), searchArgs{Query: "needle", Limit: &zero})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != defaultSearchLimit+5 {
+		t.Fatalf("limit 0 returned %d results", len(results))
+	}
+	results, err = srv.searchTool(context.Background(), searchArgs{Query: "needle"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != defaultSearchLimit {
+		t.Fatalf("omitted limit returned %d results", len(results))
+	}
+	out, err := srv.recentTool(context.Background(), recentArgs{Limit: &zero})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Items) != defaultRecentLimit+5 || out.Truncated {
+		t.Fatalf("limit 0 returned %d recent items", len(out.Items))
+	}
+	if out, err = srv.recentTool(context.Background(), recentArgs{Limit: &negative}); err != nil || len(out.Items) != defaultRecentLimit {
+		t.Fatalf("negative limit should use the default: %d items, err=%v", len(out.Items), err)
+	}
+	srv.MaxResultBytes = 1
+	if out, err = srv.recentTool(context.Background(), recentArgs{Limit: &zero}); err != nil || len(out.Items) != 1 || !out.Truncated {
+		t.Fatalf("unlimited recent should still honor the byte cap: %+v, err=%v", out, err)
+	}
+}
+
 func TestReadFileToolWithLineRange(t *testing.T) {
 	root := t.TempDir()
 	if err := os.MkdirAll(filepath.Join(root, "inbox"), 0o755); err != nil {
@@ -56,6 +206,66 @@ func TestReadFileToolWithLineRange(t *testing.T) {
 	}
 }
 
+func TestReadFileToolStripsFrontMatter(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "inbox"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "inbox", "fm.md"), []byte("---\ntags: [x]\n---\na\nb\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	srv := NewWithIO(root, true, nil, nil, nil)
+	out, err := srv.readFileTool(context.Background(), readFileArgs{Path: "inbox/fm.md", StartLine: 1, EndLine: 1, StripFrontMatter: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Content != "a" || len(out.FrontMatter["tags"].([]any)) != 1 {
+		t.Fatalf("unexpected output: %+v", out)
+	}
+	out, err = srv.readFileTool(context.Background(), readFileArgs{Path: "inbox/fm.md", Page: 1, PageSize: 1, StripFrontMatter: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Content != "a" || out.FrontMatter == nil || !out.HasMore {
+		t.Fatalf("unexpected page: %+v", out)
+	}
+	out, err = srv.readFileTool(context.Background(), readFileArgs{Path: "inbox/fm.md", StartLine: 1, EndLine: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Content != "---" || out.FrontMatter != nil {
+		t.Fatalf("default should be raw: %+v", out)
+	}
+}
+
+func TestAppendWaitsForRootLock(t *testing.T) {
+	root := t.TempDir()
+	lock, err := rootio.Lock(root, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := NewWithIO(root, false, []string{"inbox"}, nil, nil)
+	done := make(chan error, 1)
+	go func() {
+		_, err := srv.appendTool(context.Background(), appendArgs{Path: "inbox/locked.md", Content: "hello"})
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		t.Fatalf("append ran while the root lock was held: %v", err)
+	case <-time.After(150 * time.Millisecond):
+	}
+	if err := lock.Release(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "inbox", "locked.md")); err != nil {
+		t.Fatal(err)
+	}
+}
+
 func TestAppendWriteErrorsPropagate(t *testing.T) {
 	root := t.TempDir()
 	blockingFile := filepath.Join(root, "inbox")
@@ -69,3 +279,242 @@ func TestAppendWriteErrorsPropagate(t *testing.T) {
 		t.Fatal("expected write error")
 	}
 }
+
+func TestAppendEnsuresNewlineBoundary(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "inbox"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(root, "inbox", "list.md")
+	if err := os.WriteFile(path, []byte("- one"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	srv := NewWithIO(root, false, nil, nil, nil)
+	if _, err := srv.appendTool(context.Background(), appendArgs{Path: "inbox/list.md", Content: "- two\n"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := srv.appendTool(context.Background(), appendArgs{Path: "inbox/list.md", Content: "- three"}); err != nil {
+		t.Fatal(err)
+	}
+	off := false
+	if _, err := srv.appendTool(context.Background(), appendArgs{Path: "inbox/list.md", Content: " four", EnsureNewline: &off}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "- one\n- two\n- three four" {
+		t.Fatalf("unexpected content: %q", string(data))
+	}
+}
+
+func TestCreateReminderAppendsAndRegisters(t *testing.T) {
+	root := t.TempDir()
+	srv := NewWithIO(root, false, nil, nil, nil)
+	entry, err := srv.createReminderTool(context.Background(), createReminderArgs{When: "2030-01-02 10:30", Message: "call\nbob"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.SourcePath != config.Default().MCPReminderPath || entry.SourceLine != 1 || entry.Message != "call bob" {
+		t.Fatalf("unexpected entry: %+v", entry)
+	}
+	data, err := os.ReadFile(filepath.Join(root, "inbox", "reminders.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "REMIND[2030-01-02 10:30] call bob\n" {
+		t.Fatalf("unexpected content: %q", string(data))
+	}
+	if _, err := srv.createReminderTool(context.Background(), createReminderArgs{When: "tomorrow", Message: "x"}); err == nil {
+		t.Fatal("expected invalid when error")
+	}
+}
+
+func TestCreateReminderSkipsHistoryAndTracksLine(t *testing.T) {
+	root := t.TempDir()
+	history := filepath.Join(root, "scratch", "history")
+	if err := os.MkdirAll(history, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(history, "old.md"), []byte("REMIND[2030-01-01] from a snapshot\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(root, "inbox"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "inbox", "todo.md"), []byte("# todo\n\nno newline"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	srv := NewWithIO(root, false, nil, nil, nil)
+	entry, err := srv.createReminderTool(context.Background(), createReminderArgs{When: "2030-01-02", Message: "ship", Path: "inbox/todo.md"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.SourcePath != "inbox/todo.md" || entry.SourceLine != 4 || entry.Message != "ship" {
+		t.Fatalf("unexpected entry: %+v", entry)
+	}
+	entries, err := remind.LoadEntries(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		if strings.HasPrefix(e.SourcePath, "scratch/history/") {
+			t.Fatalf("history snapshot pulled into the store: %+v", e)
+		}
+	}
+}
+
+func TestListToolsHonorsReadonly(t *testing.T) {
+	names := func(readonly bool) map[string]bool {
+		srv := NewWithIO(t.TempDir(), readonly, nil, nil, nil)
+		tools, err := srv.ListTools(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		out := map[string]bool{}
+		for _, tool := range tools {
+			out[tool.Name] = true
+		}
+		return out
+	}
+	ro := names(true)
+	if !ro["search"] || ro["append"] || ro["create_reminder"] {
+		t.Fatalf("unexpected readonly tools: %v", ro)
+	}
+	rw := names(false)
+	if !rw["append"] || !rw["create_reminder"] {
+		t.Fatalf("unexpected read-write tools: %v", rw)
+	}
+}
+
+func TestInitializeAdvertisesFeatures(t *testing.T) {
+	initialize := func(features map[string]bool) map[string]any {
+		in := `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-06-18","capabilities":{},"clientInfo":{"name":"test","version":"0"}}}` + "\n"
+		var out bytes.Buffer
+		srv := NewWithIO(t.TempDir(), true, nil, strings.NewReader(in), &out)
+		srv.Features = features
+		if err := srv.Run(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		var resp struct {
+			Result struct {
+				ServerInfo map[string]any `json:"serverInfo"`
+			} `json:"result"`
+		}
+		if err := json.Unmarshal(out.Bytes(), &resp); err != nil {
+			t.Fatalf("bad response %q: %v", out.String(), err)
+		}
+		return resp.Result.ServerInfo
+	}
+	if info := initialize(nil); info["name"] != "margin" || info["features"] != nil {
+		t.Fatalf("unexpected default serverInfo: %v", info)
+	}
+	info := initialize(map[string]bool{"slack": false, "remind": true, "runblock": true})
+	b, err := json.Marshal(info["features"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info["name"] != "margin" || string(b) != `{"remind":true,"runblock":true,"slack":false}` {
+		t.Fatalf("unexpected serverInfo: %v", info)
+	}
+}
+
+func TestToolSchemasAdvertiseEnumsAndDefaults(t *testing.T) {
+	srv := NewWithIO(t.TempDir(), false, []string{"inbox", "projects"}, nil, nil)
+	srv.ReminderPath = "inbox/todo.md"
+	tools, err := srv.ListTools(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]string{
+		"search":          {`"enum":["inbox","projects"]`, `"default":["inbox","projects"]`, `"default":20`, `"description":"text to search for`},
+		"read_file":       {`"default":200`, `"description":"file path relative to the margin root"`},
+		"recent":          {`"default":20`, `"description":"maximum number of files`},
+		"append":          {`"default":true`, `"description":"text to append"`},
+		"create_reminder": {`"default":"inbox/todo.md"`, `"description":"due time as YYYY-MM-DD`},
+	}
+	for _, tool := range tools {
+		b, err := json.Marshal(tool.InputSchema)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, w := range want[tool.Name] {
+			if !strings.Contains(string(b), w) {
+				t.Fatalf("%s schema missing %s:\n%s", tool.Name, w, b)
+			}
+		}
+		delete(want, tool.Name)
+	}
+	if len(want) > 0 {
+		t.Fatalf("tools not listed: %v", want)
+	}
+}
+
+func TestCapSearchOutputTruncates(t *testing.T) {
+	results := make([]search.Result, 10)
+	for i := range results {
+		results[i] = search.Result{File: "inbox/a.md", Line: i + 1, Preview: strings.Repeat("x", 100)}
+	}
+	out := capSearchOutput(results, 300)
+	if !out.Truncated || len(out.Results) == 0 || len(out.Results) >= len(results) {
+		t.Fatalf("unexpected output: truncated=%v len=%d", out.Truncated, len(out.Results))
+	}
+	if out := capSearchOutput(results, 1<<20); out.Truncated || len(out.Results) != len(results) {
+		t.Fatalf("unexpected truncation: %+v", out)
+	}
+	srv := NewWithIO(t.TempDir(), true, nil, nil, nil)
+	if got := srv.maxResultBytes(); got != defaultMaxResultSize {
+		t.Fatalf("default cap=%d", got)
+	}
+	srv.MaxResultBytes = 300
+	if out := capSearchOutput(results, srv.maxResultBytes()); !out.Truncated {
+		t.Fatalf("configured cap not applied: %+v", out)
+	}
+	huge := []search.Result{{File: "inbox/a.md", Line: 1, Preview: strings.Repeat("word ", 200), Before: []string{strings.Repeat("y", 500)}}}
+	out = capSearchOutput(huge, 300)
+	b, err := json.Marshal(out.Results)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !out.Truncated || len(out.Results) != 1 || len(b) > 300 || out.Results[0].Before != nil || out.Results[0].Preview == "" {
+		t.Fatalf("oversized first result not trimmed to the cap (%d bytes): %+v", len(b), out)
+	}
+}
+
+func TestSafePathRejectsEscapes(t *testing.T) {
+	srv := NewWithIO(t.TempDir(), true, nil, nil, nil)
+	for _, p := range []string{"../secret", "inbox/../../secret", "/etc/passwd", `C:\Windows\system32`, "c:/windows", `\\server\share`, `\Windows`} {
+		if _, err := srv.safePath(p); err == nil {
+			t.Fatalf("expected %q to be rejected", p)
+		}
+	}
+	if _, err := srv.safePath("inbox/note.md"); err != nil {
+		t.Fatalf("expected relative path to be allowed: %v", err)
+	}
+}
+
+func TestReadFileToolPaging(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "inbox"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "inbox", "note.md"), []byte("a\nb\nc\nd\ne"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	srv := NewWithIO(root, true, nil, nil, nil)
+	out, err := srv.readFileTool(context.Background(), readFileArgs{Path: "inbox/note.md", Page: 2, PageSize: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Content != "c\nd" || !out.HasMore || out.NextPage != 3 || out.TotalLines != 5 {
+		t.Fatalf("unexpected page: %+v", out)
+	}
+	out, err = srv.readFileTool(context.Background(), readFileArgs{Path: "inbox/note.md", Page: 3, PageSize: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Content != "e" || out.HasMore || out.NextPage != 0 {
+		t.Fatalf("unexpected last page: %+v", out)
+	}
+}
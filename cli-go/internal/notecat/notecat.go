@@ -0,0 +1,99 @@
+package notecat
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"margin/internal/frontmatter"
+	"margin/internal/rootio"
+)
+
+const DefaultMaxBytes = 1024 * 1024
+
+type Options struct {
+	Glob             string
+	Hidden           bool
+	MaxBytes         int
+	StripFrontMatter bool
+}
+
+type File struct {
+	Path        string         `json:"path"`
+	Content     string         `json:"content"`
+	FrontMatter map[string]any `json:"front_matter,omitempty"`
+}
+
+type Result struct {
+	Files     []File `json:"files"`
+	Bytes     int    `json:"bytes"`
+	Truncated bool   `json:"truncated,omitempty"`
+}
+
+func Collect(ctx context.Context, root string, groups []string, opts Options) (Result, error) {
+	res := Result{Files: []File{}}
+	if opts.Glob != "" {
+		if _, err := filepath.Match(opts.Glob, ""); err != nil {
+			return res, fmt.Errorf("invalid glob %q: %w", opts.Glob, err)
+		}
+	}
+	maxBytes := opts.MaxBytes
+	if maxBytes <= 0 {
+		maxBytes = DefaultMaxBytes
+	}
+	files, err := rootio.ListFilesRecursive(rootio.ResolvePathGroups(root, groups), rootio.WalkOptions{Hidden: opts.Hidden})
+	if err != nil {
+		return res, err
+	}
+	for _, f := range files {
+		if err := ctx.Err(); err != nil {
+			return res, err
+		}
+		rel, err := rootio.RelUnderRoot(root, f)
+		if err != nil {
+			continue
+		}
+		if opts.Glob != "" {
+			if ok, _ := filepath.Match(opts.Glob, filepath.Base(f)); !ok {
+				continue
+			}
+		}
+		data, err := os.ReadFile(f)
+		if err != nil || rootio.IsBinaryData(data) {
+			continue
+		}
+		var meta map[string]any
+		if opts.StripFrontMatter {
+			var body string
+			if meta, body, _ = frontmatter.Split(string(data)); meta != nil {
+				data = []byte(body)
+			}
+		}
+		if res.Bytes+len(data) > maxBytes {
+			res.Truncated = true
+			if res.Bytes == maxBytes {
+				break
+			}
+			data = data[:maxBytes-res.Bytes]
+		}
+		res.Files = append(res.Files, File{Path: rel, Content: strings.ToValidUTF8(string(data), ""), FrontMatter: meta})
+		res.Bytes += len(data)
+		if res.Truncated {
+			break
+		}
+	}
+	return res, nil
+}
+
+func (r Result) Raw() string {
+	var sb strings.Builder
+	for _, f := range r.Files {
+		sb.WriteString(f.Content)
+		if f.Content != "" && !strings.HasSuffix(f.Content, "\n") {
+			sb.WriteByte('\n')
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,70 @@
+package notecat
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCollectFiltersByGlobAndCapsBytes(t *testing.T) {
+	root := t.TempDir()
+	inbox := filepath.Join(root, "inbox")
+	if err := os.MkdirAll(inbox, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"2024-01.md": "first\n",
+		"2024-02.md": "second",
+		"notes.md":   "skip me\n",
+		"2024-03.md": "third\n",
+	}
+	for name, body := range files {
+		if err := os.WriteFile(filepath.Join(inbox, name), []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	res, err := Collect(context.Background(), root, []string{"inbox"}, Options{Glob: "2024-*"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Files) != 3 || res.Files[0].Path != "inbox/2024-01.md" || res.Truncated {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	if got := res.Raw(); got != "first\nsecond\nthird\n" {
+		t.Fatalf("raw=%q", got)
+	}
+
+	res, err = Collect(context.Background(), root, []string{"inbox"}, Options{Glob: "2024-*", MaxBytes: 9})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Truncated || len(res.Files) != 2 || res.Files[1].Content != "sec" || res.Bytes != 9 {
+		t.Fatalf("unexpected capped result: %+v", res)
+	}
+}
+
+func TestCollectStripsFrontMatter(t *testing.T) {
+	root := t.TempDir()
+	inbox := filepath.Join(root, "inbox")
+	if err := os.MkdirAll(inbox, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(inbox, "a.md"), []byte("---\ntitle: A\n---\nbody\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	res, err := Collect(context.Background(), root, []string{"inbox"}, Options{StripFrontMatter: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Files) != 1 || res.Files[0].Content != "body\n" || res.Files[0].FrontMatter["title"] != "A" {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	res, err = Collect(context.Background(), root, []string{"inbox"}, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Files[0].Content != "---\ntitle: A\n---\nbody\n" || res.Files[0].FrontMatter != nil {
+		t.Fatalf("default should be raw: %+v", res)
+	}
+}
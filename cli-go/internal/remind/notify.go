@@ -0,0 +1,147 @@
+package remind
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/google/shlex"
+
+	"margin/internal/config"
+)
+
+const (
+	NotifierDesktop = "desktop"
+	NotifierCommand = "command"
+	NotifierWebhook = "webhook"
+
+	notifyTimeout = 10 * time.Second
+)
+
+type NotifyResult struct {
+	ID       string `json:"id"`
+	Notifier string `json:"notifier"`
+	OK       bool   `json:"ok"`
+	Error    string `json:"error,omitempty"`
+}
+
+type notifyFunc func(ctx context.Context, cfg config.RemindConfig, e Entry) error
+
+var notifierFuncs = map[string]notifyFunc{
+	NotifierDesktop: func(ctx context.Context, _ config.RemindConfig, e Entry) error {
+		return sendNotification(ctx, e.Message)
+	},
+	NotifierCommand: notifyCommand,
+	NotifierWebhook: notifyWebhook,
+}
+
+func notifyAll(ctx context.Context, cfg config.RemindConfig, e Entry) []NotifyResult {
+	names := cfg.Notifiers
+	if len(names) == 0 {
+		names = []string{NotifierDesktop}
+	}
+	out := make([]NotifyResult, 0, len(names))
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		res := NotifyResult{ID: e.ID, Notifier: name}
+		fn, ok := notifierFuncs[name]
+		if !ok {
+			res.Error = fmt.Sprintf("unknown notifier: %s", name)
+			out = append(out, res)
+			continue
+		}
+		if err := fn(ctx, cfg, e); err != nil {
+			res.Error = err.Error()
+		} else {
+			res.OK = true
+		}
+		out = append(out, res)
+	}
+	return out
+}
+
+type TestNotifyResult struct {
+	OK            bool           `json:"ok"`
+	Notifications []NotifyResult `json:"notifications"`
+}
+
+func TestNotify(ctx context.Context, cfg config.RemindConfig, notifier, message string) TestNotifyResult {
+	if strings.TrimSpace(notifier) != "" {
+		cfg.Notifiers = []string{notifier}
+	}
+	res := TestNotifyResult{
+		OK:            true,
+		Notifications: notifyAll(ctx, cfg, Entry{ID: "test-notify", When: time.Now().Format(time.RFC3339), Message: message}),
+	}
+	for _, n := range res.Notifications {
+		if !n.OK {
+			res.OK = false
+		}
+	}
+	return res
+}
+
+func notifyCommand(ctx context.Context, cfg config.RemindConfig, e Entry) error {
+	parts, err := shlex.Split(cfg.Command)
+	if err != nil {
+		return fmt.Errorf("invalid remind.command: %w", err)
+	}
+	if len(parts) == 0 {
+		return errors.New("remind.command is not configured")
+	}
+	timeoutCtx, cancel := context.WithTimeout(ctx, notifyTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(timeoutCtx, parts[0], parts[1:]...)
+	cmd.Stdin = strings.NewReader(e.Message)
+	cmd.Env = append(os.Environ(),
+		"MARGIN_REMINDER_ID="+e.ID,
+		"MARGIN_REMINDER_WHEN="+e.When,
+		"MARGIN_REMINDER_MESSAGE="+e.Message,
+		"MARGIN_REMINDER_SOURCE="+e.SourcePath,
+	)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		if msg := strings.TrimSpace(string(out)); msg != "" {
+			return fmt.Errorf("%w: %s", err, msg)
+		}
+		return err
+	}
+	return nil
+}
+
+func notifyWebhook(ctx context.Context, cfg config.RemindConfig, e Entry) error {
+	if strings.TrimSpace(cfg.WebhookURL) == "" {
+		return errors.New("remind.webhook_url is not configured")
+	}
+	body, err := json.Marshal(map[string]string{
+		"text":        e.Message,
+		"id":          e.ID,
+		"when":        e.When,
+		"source_path": e.SourcePath,
+	})
+	if err != nil {
+		return err
+	}
+	timeoutCtx, cancel := context.WithTimeout(ctx, notifyTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(timeoutCtx, http.MethodPost, cfg.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
 	"regexp"
 	"runtime"
@@ -15,10 +16,13 @@ import (
 	"strings"
 	"time"
 
+	"margin/internal/config"
 	"margin/internal/rootio"
 )
 
-var remindRe = regexp.MustCompile(`REMIND\[([^\]]+)\]\s*(.+)$`)
+var remindRe = regexp.MustCompile(`REMIND\[([^\]]+)\]\s*(.*)$`)
+
+const DefaultMessageTemplate = "Reminder from {source}"
 
 type Entry struct {
 	ID         string `json:"id"`
@@ -34,48 +38,247 @@ type Store struct {
 	Entries []Entry `json:"entries"`
 }
 
+type firedIndex struct {
+	IDs []string `json:"ids"`
+}
+
+type ScanOptions struct {
+	IncludeHistory  bool
+	Hidden          bool
+	DedupeByMessage bool
+	DefaultMessage  string
+	ArchiveFired    bool
+	SourceFilter    string
+	DryRun          bool
+	Preview         bool
+}
+
 type ScanResult struct {
-	Found int `json:"found"`
-	Added int `json:"added"`
-	Total int `json:"total"`
+	Found      int           `json:"found"`
+	Added      int           `json:"added"`
+	Total      int           `json:"total"`
+	Suppressed int           `json:"suppressed,omitempty"`
+	DryRun     bool          `json:"dry_run,omitempty"`
+	WouldAdd   []Entry       `json:"would_add,omitempty"`
+	Previews   []ScanPreview `json:"previews,omitempty"`
+}
+
+type ScanPreview struct {
+	ID         string `json:"id"`
+	SourcePath string `json:"source_path"`
+	SourceLine int    `json:"source_line"`
+	Line       string `json:"line"`
+	Before     string `json:"before,omitempty"`
+	After      string `json:"after,omitempty"`
+}
+
+type DigestOptions struct {
+	Notify bool
+	Config config.RemindConfig
+}
+
+type DigestGroup struct {
+	Date    string  `json:"date"`
+	Entries []Entry `json:"entries"`
+}
+
+type DigestResult struct {
+	Total         int            `json:"total"`
+	Groups        []DigestGroup  `json:"groups"`
+	Notifications []NotifyResult `json:"notifications,omitempty"`
+}
+
+type RebuildResult struct {
+	Found      int `json:"found"`
+	Kept       int `json:"kept"`
+	Added      int `json:"added"`
+	Dropped    int `json:"dropped"`
+	Total      int `json:"total"`
+	Suppressed int `json:"suppressed,omitempty"`
+	Archived   int `json:"archived,omitempty"`
+}
+
+const (
+	CatchUpFireAll    = "fire-all"
+	CatchUpFireLatest = "fire-latest"
+	CatchUpMarkSilent = "mark-silent"
+)
+
+type ScheduleOptions struct {
+	Notify  bool
+	CatchUp string
+	Config  config.RemindConfig
+}
+
+type FiredEntry struct {
+	Entry
+	LateBySeconds int64 `json:"late_by_seconds"`
 }
 
 type ScheduleResult struct {
-	Due []Entry `json:"due"`
+	Due           []FiredEntry   `json:"due"`
+	Silenced      []Entry        `json:"silenced,omitempty"`
+	Stale         []Entry        `json:"stale,omitempty"`
+	Archived      int            `json:"archived,omitempty"`
+	Notifications []NotifyResult `json:"notifications,omitempty"`
 }
 
-func Scan(ctx context.Context, root string, includeHistory bool) (ScanResult, error) {
-	if err := ctx.Err(); err != nil {
+func Scan(ctx context.Context, root string, opts ScanOptions) (ScanResult, error) {
+	entries, err := collectEntries(ctx, root, opts)
+	if err != nil {
 		return ScanResult{}, err
 	}
-	groups := []string{"scratch", "inbox", "slack"}
-	paths := rootio.ResolvePathGroups(root, groups)
-	if !includeHistory {
-		filtered := make([]string, 0, len(paths))
-		for _, p := range paths {
-			if strings.HasSuffix(filepath.ToSlash(p), "scratch/history") {
+	store, err := loadStore(root)
+	if err != nil {
+		return ScanResult{}, err
+	}
+	known := map[string]bool{}
+	seen := map[string]bool{}
+	for _, e := range store.Entries {
+		known[e.ID] = true
+		seen[messageKey(e)] = true
+	}
+	firedIDs, indexed, err := loadFiredIndex(root)
+	if err != nil {
+		return ScanResult{}, err
+	}
+	for _, id := range firedIDs {
+		known[id] = true
+	}
+	// The archive itself is only read when a genuinely new reminder needs its
+	// message keys for dedupe (or when the archive predates the ID index).
+	archiveLoaded := false
+	loadArchiveKeys := func() error {
+		if archiveLoaded {
+			return nil
+		}
+		archiveLoaded = true
+		archive, err := loadArchive(root)
+		if err != nil {
+			return err
+		}
+		for _, e := range archive.Entries {
+			known[e.ID] = true
+			seen[messageKey(e)] = true
+		}
+		return nil
+	}
+	added, suppressed := 0, 0
+	var newEntries []Entry
+	for _, entry := range entries {
+		if known[entry.ID] {
+			continue
+		}
+		if opts.DedupeByMessage || !indexed {
+			if err := loadArchiveKeys(); err != nil {
+				return ScanResult{}, err
+			}
+			if known[entry.ID] {
 				continue
 			}
-			filtered = append(filtered, p)
 		}
-		paths = filtered
+		if opts.DedupeByMessage && seen[messageKey(entry)] {
+			suppressed++
+			continue
+		}
+		store.Entries = append(store.Entries, entry)
+		known[entry.ID] = true
+		seen[messageKey(entry)] = true
+		newEntries = append(newEntries, entry)
+		added++
 	}
-	files, err := rootio.ListFilesRecursive(paths)
-	if err != nil {
+	res := ScanResult{Found: len(entries), Added: added, Total: len(store.Entries), Suppressed: suppressed}
+	if opts.Preview {
+		res.Previews = previewEntries(root, newEntries)
+	}
+	if opts.DryRun {
+		sortEntries(newEntries)
+		res.DryRun = true
+		res.WouldAdd = newEntries
+		return res, nil
+	}
+	sortEntries(store.Entries)
+	if err := saveStore(root, store); err != nil {
 		return ScanResult{}, err
 	}
-	store, err := loadStore(root)
+	return res, nil
+}
+
+func Rebuild(ctx context.Context, root string, opts ScanOptions) (RebuildResult, error) {
+	entries, err := collectEntries(ctx, root, opts)
 	if err != nil {
-		return ScanResult{}, err
+		return RebuildResult{}, err
 	}
-	known := map[string]Entry{}
-	for _, e := range store.Entries {
-		known[e.ID] = e
+	old, err := loadStore(root)
+	if err != nil {
+		return RebuildResult{}, err
+	}
+	archive, err := loadArchive(root)
+	if err != nil {
+		return RebuildResult{}, err
+	}
+	prev := make(map[string]Entry, len(old.Entries)+len(archive.Entries))
+	for _, e := range append(archive.Entries, old.Entries...) {
+		prev[e.ID] = e
 	}
-	found, added := 0, 0
+	res := RebuildResult{Found: len(entries)}
+	if opts.DedupeByMessage {
+		entries, res.Suppressed = collapseByMessage(entries, prev)
+	}
+	for i := range entries {
+		if p, ok := prev[entries[i].ID]; ok {
+			entries[i].Fired = p.Fired
+			entries[i].FiredAt = p.FiredAt
+			delete(prev, entries[i].ID)
+			res.Kept++
+			continue
+		}
+		res.Added++
+	}
+	res.Dropped = len(prev)
+	sortEntries(entries)
+	active, fired := entries, []Entry(nil)
+	if opts.ArchiveFired {
+		active, fired = splitFired(entries)
+	}
+	if err := saveArchive(root, Store{Entries: fired}); err != nil {
+		return RebuildResult{}, err
+	}
+	if err := saveStore(root, Store{Entries: active}); err != nil {
+		return RebuildResult{}, err
+	}
+	res.Total = len(entries)
+	res.Archived = len(fired)
+	return res, nil
+}
+
+func collectEntries(ctx context.Context, root string, opts ScanOptions) ([]Entry, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	groups := []string{"scratch", "inbox", "slack"}
+	paths := rootio.ResolvePathGroups(root, groups)
+	if !opts.IncludeHistory {
+		paths = rootio.WithoutHistory(paths)
+	}
+	if err := ValidSourceFilter(opts.SourceFilter); err != nil {
+		return nil, fmt.Errorf("source filter %q: %w", opts.SourceFilter, err)
+	}
+	files, err := rootio.ListFilesRecursive(paths, rootio.WalkOptions{Hidden: opts.Hidden})
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]Entry, 0)
 	for _, f := range files {
 		if err := ctx.Err(); err != nil {
-			return ScanResult{}, err
+			return nil, err
+		}
+		rel, err := rootio.RelUnderRoot(root, f)
+		if err != nil {
+			rel = filepath.ToSlash(f)
+		}
+		if opts.SourceFilter != "" && !matchSource(opts.SourceFilter, rel) {
+			continue
 		}
 		data, err := os.ReadFile(f)
 		if err != nil {
@@ -87,49 +290,136 @@ func Scan(ctx context.Context, root string, includeHistory bool) (ScanResult, er
 			if len(m) != 3 {
 				continue
 			}
-			when, err := parseWhen(m[1])
+			when, err := ParseWhen(m[1])
 			if err != nil {
 				continue
 			}
-			rel, err := rootio.RelUnderRoot(root, f)
-			if err != nil {
-				rel = filepath.ToSlash(f)
-			}
-			id := hashID(rel, i+1, when.Format(time.RFC3339), m[2])
-			found++
-			if _, ok := known[id]; ok {
-				continue
+			msg := strings.TrimSpace(m[2])
+			if msg == "" {
+				msg = defaultMessage(opts.DefaultMessage, rel)
 			}
-			entry := Entry{
-				ID:         id,
+			entries = append(entries, Entry{
+				ID:         hashID(rel, i+1, when.Format(time.RFC3339), m[2]),
 				When:       when.Format(time.RFC3339),
-				Message:    strings.TrimSpace(m[2]),
+				Message:    msg,
 				SourcePath: rel,
 				SourceLine: i + 1,
+			})
+		}
+	}
+	return entries, nil
+}
+
+func ValidSourceFilter(pattern string) error {
+	_, err := path.Match(pattern, "")
+	return err
+}
+
+func matchSource(pattern, rel string) bool {
+	pattern = strings.TrimSuffix(pattern, "/")
+	for p := rel; p != "." && p != "/" && p != ""; p = path.Dir(p) {
+		if ok, _ := path.Match(pattern, p); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func defaultMessage(template, source string) string {
+	if strings.TrimSpace(template) == "" {
+		template = DefaultMessageTemplate
+	}
+	return strings.ReplaceAll(template, "{source}", source)
+}
+
+func previewEntries(root string, entries []Entry) []ScanPreview {
+	out := make([]ScanPreview, 0, len(entries))
+	files := map[string][]string{}
+	for _, e := range entries {
+		lines, ok := files[e.SourcePath]
+		if !ok {
+			p := filepath.FromSlash(e.SourcePath)
+			if !filepath.IsAbs(p) {
+				p = filepath.Join(root, p)
+			}
+			if data, err := os.ReadFile(p); err == nil {
+				lines = strings.Split(string(data), "\n")
 			}
-			store.Entries = append(store.Entries, entry)
-			known[id] = entry
-			added++
+			files[e.SourcePath] = lines
 		}
+		pv := ScanPreview{ID: e.ID, SourcePath: e.SourcePath, SourceLine: e.SourceLine}
+		i := e.SourceLine - 1
+		if i >= 0 && i < len(lines) {
+			pv.Line = strings.TrimRight(lines[i], "\r")
+			if i > 0 {
+				pv.Before = strings.TrimSpace(lines[i-1])
+			}
+			if i+1 < len(lines) {
+				pv.After = strings.TrimSpace(lines[i+1])
+			}
+		}
+		out = append(out, pv)
 	}
-	sort.Slice(store.Entries, func(i, j int) bool { return store.Entries[i].When < store.Entries[j].When })
-	if err := saveStore(root, store); err != nil {
-		return ScanResult{}, err
+	return out
+}
+
+func messageKey(e Entry) string {
+	return e.When + "\x00" + e.Message
+}
+
+func collapseByMessage(entries []Entry, prev map[string]Entry) ([]Entry, int) {
+	// Keep one entry per message key, preferring the one already in the store so
+	// its fired state carries over, and otherwise the first one found (as Scan does).
+	keep := map[string]int{}
+	for i, e := range entries {
+		k := messageKey(e)
+		j, ok := keep[k]
+		if !ok {
+			keep[k] = i
+			continue
+		}
+		if _, stored := prev[entries[j].ID]; stored {
+			continue
+		}
+		if _, stored := prev[e.ID]; stored {
+			keep[k] = i
+		}
+	}
+	out := make([]Entry, 0, len(keep))
+	for i, e := range entries {
+		if keep[messageKey(e)] == i {
+			out = append(out, e)
+		}
 	}
-	return ScanResult{Found: found, Added: added, Total: len(store.Entries)}, nil
+	return out, len(entries) - len(out)
 }
 
-func Schedule(ctx context.Context, root string, notify bool) (ScheduleResult, error) {
+func sortEntries(entries []Entry) {
+	sort.Slice(entries, func(i, j int) bool { return entries[i].When < entries[j].When })
+}
+
+func Schedule(ctx context.Context, root string, opts ScheduleOptions) (ScheduleResult, error) {
 	if err := ctx.Err(); err != nil {
 		return ScheduleResult{}, err
 	}
+	catchUp, err := parseCatchUp(opts.CatchUp)
+	if err != nil {
+		return ScheduleResult{}, err
+	}
+	var maxOverdue time.Duration
+	if opts.Config.NotifyMaxOverdue != "" {
+		maxOverdue, err = config.ParseRelativeDuration(opts.Config.NotifyMaxOverdue)
+		if err != nil {
+			return ScheduleResult{}, fmt.Errorf("invalid remind.notify_max_overdue: %w", err)
+		}
+	}
 	store, err := loadStore(root)
 	if err != nil {
 		return ScheduleResult{}, err
 	}
 	now := time.Now()
-	due := make([]Entry, 0)
-	changed := false
+	overdue := make([]int, 0)
+	stale := make([]int, 0)
 	for i := range store.Entries {
 		if err := ctx.Err(); err != nil {
 			return ScheduleResult{}, err
@@ -145,23 +435,250 @@ func Schedule(ctx context.Context, root string, notify bool) (ScheduleResult, er
 		if when.After(now) {
 			continue
 		}
+		if maxOverdue > 0 && now.Sub(when) > maxOverdue {
+			stale = append(stale, i)
+			continue
+		}
+		overdue = append(overdue, i)
+	}
+	fire := selectFiring(store.Entries, overdue, catchUp)
+	res := ScheduleResult{Due: make([]FiredEntry, 0)}
+	for _, i := range stale {
+		e := &store.Entries[i]
 		e.Fired = true
 		e.FiredAt = now.Format(time.RFC3339)
-		due = append(due, *e)
-		changed = true
-		if notify {
-			_ = sendNotification(ctx, e.Message)
+		res.Stale = append(res.Stale, *e)
+	}
+	for _, i := range overdue {
+		e := &store.Entries[i]
+		e.Fired = true
+		e.FiredAt = now.Format(time.RFC3339)
+		if !fire[i] {
+			res.Silenced = append(res.Silenced, *e)
+			continue
 		}
+		fired := FiredEntry{Entry: *e}
+		if when, err := time.Parse(time.RFC3339, e.When); err == nil {
+			fired.LateBySeconds = int64(now.Sub(when) / time.Second)
+		}
+		res.Due = append(res.Due, fired)
 	}
-	if changed {
+	// Persist fired state before notifying so an interrupted run cannot re-fire.
+	if len(overdue) > 0 || len(stale) > 0 {
 		if err := saveStore(root, store); err != nil {
 			return ScheduleResult{}, err
 		}
 	}
-	return ScheduleResult{Due: due}, nil
+	if opts.Notify {
+		for _, e := range res.Due {
+			res.Notifications = append(res.Notifications, notifyAll(ctx, opts.Config, e.Entry)...)
+		}
+	}
+	// Archive only after fired state is saved: a crash in between leaves a
+	// fired duplicate in the active store instead of a reminder that re-fires.
+	if opts.Config.ArchiveFired {
+		n, err := archiveFired(root, store)
+		if err != nil {
+			return res, err
+		}
+		res.Archived = n
+	}
+	return res, nil
 }
 
-func parseWhen(raw string) (time.Time, error) {
+func archiveFired(root string, store Store) (int, error) {
+	active, fired := splitFired(store.Entries)
+	if len(fired) == 0 {
+		return 0, nil
+	}
+	archive, err := loadArchive(root)
+	if err != nil {
+		return 0, err
+	}
+	byID := make(map[string]int, len(archive.Entries))
+	for i, e := range archive.Entries {
+		byID[e.ID] = i
+	}
+	for _, e := range fired {
+		if i, ok := byID[e.ID]; ok {
+			archive.Entries[i] = e
+			continue
+		}
+		byID[e.ID] = len(archive.Entries)
+		archive.Entries = append(archive.Entries, e)
+	}
+	sortEntries(archive.Entries)
+	if err := saveArchive(root, archive); err != nil {
+		return 0, err
+	}
+	if err := saveStore(root, Store{Entries: active}); err != nil {
+		return 0, err
+	}
+	return len(fired), nil
+}
+
+func splitFired(entries []Entry) ([]Entry, []Entry) {
+	active := make([]Entry, 0, len(entries))
+	var fired []Entry
+	for _, e := range entries {
+		if e.Fired {
+			fired = append(fired, e)
+			continue
+		}
+		active = append(active, e)
+	}
+	return active, fired
+}
+
+func Digest(ctx context.Context, root string, opts DigestOptions) (DigestResult, error) {
+	if err := ctx.Err(); err != nil {
+		return DigestResult{}, err
+	}
+	store, err := loadStore(root)
+	if err != nil {
+		return DigestResult{}, err
+	}
+	now := time.Now()
+	res := DigestResult{Groups: make([]DigestGroup, 0)}
+	byDate := map[string]int{}
+	for _, e := range store.Entries {
+		if e.Fired {
+			continue
+		}
+		when, err := time.Parse(time.RFC3339, e.When)
+		if err != nil || when.After(now) {
+			continue
+		}
+		date := when.In(time.Local).Format("2006-01-02")
+		i, ok := byDate[date]
+		if !ok {
+			i = len(res.Groups)
+			byDate[date] = i
+			res.Groups = append(res.Groups, DigestGroup{Date: date})
+		}
+		res.Groups[i].Entries = append(res.Groups[i].Entries, e)
+		res.Total++
+	}
+	sort.Slice(res.Groups, func(i, j int) bool { return res.Groups[i].Date < res.Groups[j].Date })
+	if opts.Notify && res.Total > 0 {
+		summary := Entry{ID: "digest", When: now.Format(time.RFC3339), Message: digestMessage(res)}
+		res.Notifications = notifyAll(ctx, opts.Config, summary)
+	}
+	return res, nil
+}
+
+func Next(ctx context.Context, root string, overdue bool) (*Entry, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	store, err := loadStore(root)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	var best *Entry
+	var bestWhen time.Time
+	for i := range store.Entries {
+		e := &store.Entries[i]
+		if e.Fired {
+			continue
+		}
+		when, err := time.Parse(time.RFC3339, e.When)
+		if err != nil || when.After(now) == overdue {
+			continue
+		}
+		if best == nil || when.Before(bestWhen) {
+			best = e
+			bestWhen = when
+		}
+	}
+	return best, nil
+}
+
+func digestMessage(res DigestResult) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%d overdue reminder(s)", res.Total)
+	for _, g := range res.Groups {
+		fmt.Fprintf(&sb, "\n%s:", g.Date)
+		for _, e := range g.Entries {
+			fmt.Fprintf(&sb, "\n- %s", e.Message)
+		}
+	}
+	return sb.String()
+}
+
+func ValidCatchUp(mode string) bool {
+	_, err := parseCatchUp(mode)
+	return err == nil
+}
+
+func parseCatchUp(raw string) (string, error) {
+	switch raw {
+	case "":
+		return CatchUpFireAll, nil
+	case CatchUpFireAll, CatchUpFireLatest, CatchUpMarkSilent:
+		return raw, nil
+	default:
+		return "", fmt.Errorf("invalid catch-up mode: %s", raw)
+	}
+}
+
+func selectFiring(entries []Entry, overdue []int, catchUp string) map[int]bool {
+	fire := make(map[int]bool, len(overdue))
+	switch catchUp {
+	case CatchUpMarkSilent:
+		return fire
+	case CatchUpFireLatest:
+		latest := map[string]int{}
+		for _, i := range overdue {
+			src := entries[i].SourcePath
+			if j, ok := latest[src]; !ok || entries[i].When >= entries[j].When {
+				latest[src] = i
+			}
+		}
+		for _, i := range latest {
+			fire[i] = true
+		}
+	default:
+		for _, i := range overdue {
+			fire[i] = true
+		}
+	}
+	return fire
+}
+
+func LoadEntries(root string) ([]Entry, error) {
+	st, err := loadStore(root)
+	if err != nil {
+		return nil, err
+	}
+	return st.Entries, nil
+}
+
+func LoadAllEntries(root string) ([]Entry, error) {
+	st, err := loadStore(root)
+	if err != nil {
+		return nil, err
+	}
+	archive, err := loadArchive(root)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]Entry, 0, len(st.Entries)+len(archive.Entries))
+	index := map[string]int{}
+	for _, e := range append(archive.Entries, st.Entries...) {
+		if i, ok := index[e.ID]; ok {
+			entries[i] = e
+			continue
+		}
+		index[e.ID] = len(entries)
+		entries = append(entries, e)
+	}
+	sortEntries(entries)
+	return entries, nil
+}
+
+func ParseWhen(raw string) (time.Time, error) {
 	raw = strings.TrimSpace(raw)
 	if len(raw) == len("2006-01-02") {
 		t, err := time.ParseInLocation("2006-01-02", raw, time.Local)
@@ -182,12 +699,27 @@ func hashID(parts ...any) string {
 	return hex.EncodeToString(h.Sum(nil))
 }
 
-func storePath(root string) string {
+func StorePath(root string) string {
 	return filepath.Join(root, "index", "reminders.json")
 }
 
+func ArchivePath(root string) string {
+	return filepath.Join(root, "index", "reminders_fired.json")
+}
+
+func firedIndexPath(root string) string {
+	return filepath.Join(root, "index", "reminders_fired_ids.json")
+}
+
 func loadStore(root string) (Store, error) {
-	p := storePath(root)
+	return loadStoreFile(StorePath(root))
+}
+
+func loadArchive(root string) (Store, error) {
+	return loadStoreFile(ArchivePath(root))
+}
+
+func loadStoreFile(p string) (Store, error) {
 	data, err := os.ReadFile(p)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -202,24 +734,80 @@ func loadStore(root string) (Store, error) {
 	return st, nil
 }
 
+func loadFiredIndex(root string) ([]string, bool, error) {
+	data, err := os.ReadFile(firedIndexPath(root))
+	if err != nil {
+		if os.IsNotExist(err) {
+			_, statErr := os.Stat(ArchivePath(root))
+			// No archive means nothing was ever fired into it, which is as good as an empty index.
+			return nil, os.IsNotExist(statErr), nil
+		}
+		return nil, false, err
+	}
+	var idx firedIndex
+	if err := json.Unmarshal(data, &idx); err != nil {
+		return nil, false, err
+	}
+	return idx.IDs, true, nil
+}
+
 func saveStore(root string, st Store) error {
+	return saveStoreFile(StorePath(root), st)
+}
+
+func saveArchive(root string, st Store) error {
+	if len(st.Entries) == 0 {
+		for _, p := range []string{ArchivePath(root), firedIndexPath(root)} {
+			if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := saveStoreFile(ArchivePath(root), st); err != nil {
+		return err
+	}
+	idx := firedIndex{IDs: make([]string, len(st.Entries))}
+	for i, e := range st.Entries {
+		idx.IDs[i] = e.ID
+	}
+	sort.Strings(idx.IDs)
+	b, err := json.MarshalIndent(idx, "", "  ")
+	if err != nil {
+		return err
+	}
+	return rootio.AtomicWriteFile(firedIndexPath(root), b, 0o644)
+}
+
+func saveStoreFile(p string, st Store) error {
 	b, err := json.MarshalIndent(st, "", "  ")
 	if err != nil {
 		return err
 	}
-	return rootio.AtomicWriteFile(storePath(root), b, 0o644)
+	return rootio.AtomicWriteFile(p, b, 0o644)
 }
 
 func sendNotification(ctx context.Context, msg string) error {
 	switch runtime.GOOS {
 	case "darwin":
-		return exec.CommandContext(ctx, "osascript", "-e", fmt.Sprintf("display notification %q with title \"Margin Reminder\"", msg)).Run()
+		return runNotifier(exec.CommandContext(ctx, "osascript", "-e", fmt.Sprintf("display notification %q with title \"Margin Reminder\"", msg)))
 	case "linux":
-		return exec.CommandContext(ctx, "notify-send", "Margin Reminder", msg).Run()
+		return runNotifier(exec.CommandContext(ctx, "notify-send", "Margin Reminder", msg))
 	case "windows":
 		script := fmt.Sprintf("[Windows.UI.Notifications.ToastNotificationManager, Windows.UI.Notifications, ContentType = WindowsRuntime] > $null; [Windows.Data.Xml.Dom.XmlDocument, Windows.Data.Xml.Dom.XmlDocument, ContentType = WindowsRuntime] > $null; $template = [Windows.UI.Notifications.ToastTemplateType]::ToastText02; $xml = [Windows.UI.Notifications.ToastNotificationManager]::GetTemplateContent($template); $textNodes = $xml.GetElementsByTagName('text'); $textNodes.Item(0).AppendChild($xml.CreateTextNode('Margin Reminder')) > $null; $textNodes.Item(1).AppendChild($xml.CreateTextNode('%s')) > $null; $toast = [Windows.UI.Notifications.ToastNotification]::new($xml); $notifier = [Windows.UI.Notifications.ToastNotificationManager]::CreateToastNotifier('Margin'); $notifier.Show($toast)", strings.ReplaceAll(msg, "'", "''"))
-		return exec.CommandContext(ctx, "powershell", "-NoProfile", "-Command", script).Run()
+		return runNotifier(exec.CommandContext(ctx, "powershell", "-NoProfile", "-Command", script))
 	default:
 		return nil
 	}
 }
+
+func runNotifier(cmd *exec.Cmd) error {
+	out, err := cmd.CombinedOutput()
+	if err == nil {
+		return nil
+	}
+	if msg := strings.TrimSpace(string(out)); msg != "" {
+		return fmt.Errorf("%s: %w: %s", filepath.Base(cmd.Path), err, msg)
+	}
+	return err
+}
@@ -1,17 +1,577 @@
 package remind
 
-import "testing"
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"margin/internal/config"
+)
 
 func TestParseWhen(t *testing.T) {
-	tm, err := parseWhen("2026-01-02")
+	tm, err := ParseWhen("2026-01-02")
 	if err != nil {
 		t.Fatal(err)
 	}
 	if tm.Hour() != 9 || tm.Minute() != 0 {
 		t.Fatalf("unexpected default time: %v", tm)
 	}
-	_, err = parseWhen("2026-01-02 14:05")
+	_, err = ParseWhen("2026-01-02 14:05")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestScheduleCatchUpFireLatest(t *testing.T) {
+	root := t.TempDir()
+	now := time.Now()
+	st := Store{Entries: []Entry{
+		{ID: "a1", When: now.Add(-48 * time.Hour).Format(time.RFC3339), SourcePath: "inbox/a.md"},
+		{ID: "a2", When: now.Add(-24 * time.Hour).Format(time.RFC3339), SourcePath: "inbox/a.md"},
+		{ID: "b1", When: now.Add(-72 * time.Hour).Format(time.RFC3339), SourcePath: "inbox/b.md"},
+		{ID: "c1", When: now.Add(24 * time.Hour).Format(time.RFC3339), SourcePath: "inbox/c.md"},
+	}}
+	if err := saveStore(root, st); err != nil {
+		t.Fatal(err)
+	}
+	res, err := Schedule(context.Background(), root, ScheduleOptions{CatchUp: CatchUpFireLatest})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Due) != 2 || len(res.Silenced) != 1 || res.Silenced[0].ID != "a1" {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	loaded, err := loadStore(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range loaded.Entries {
+		if e.Fired != (e.ID != "c1") {
+			t.Fatalf("unexpected fired state: %+v", e)
+		}
+	}
+}
+
+func TestScheduleCatchUpMarkSilent(t *testing.T) {
+	root := t.TempDir()
+	st := Store{Entries: []Entry{
+		{ID: "a1", When: time.Now().Add(-time.Hour).Format(time.RFC3339), SourcePath: "inbox/a.md"},
+	}}
+	if err := saveStore(root, st); err != nil {
+		t.Fatal(err)
+	}
+	res, err := Schedule(context.Background(), root, ScheduleOptions{Notify: true, CatchUp: CatchUpMarkSilent})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Due) != 0 || len(res.Silenced) != 1 {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+}
+
+func TestScheduleNotifyMaxOverdueMarksStale(t *testing.T) {
+	root := t.TempDir()
+	now := time.Now()
+	st := Store{Entries: []Entry{
+		{ID: "old", When: now.Add(-10 * 24 * time.Hour).Format(time.RFC3339), SourcePath: "inbox/a.md"},
+		{ID: "new", When: now.Add(-time.Hour).Format(time.RFC3339), SourcePath: "inbox/b.md"},
+	}}
+	if err := saveStore(root, st); err != nil {
+		t.Fatal(err)
+	}
+	res, err := Schedule(context.Background(), root, ScheduleOptions{Config: config.RemindConfig{NotifyMaxOverdue: "3d"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Due) != 1 || res.Due[0].ID != "new" || len(res.Stale) != 1 || res.Stale[0].ID != "old" {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	if late := res.Due[0].LateBySeconds; late < 3590 || late > 3610 {
+		t.Fatalf("late_by_seconds=%d, want about 3600", late)
+	}
+	loaded, err := loadStore(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range loaded.Entries {
+		if !e.Fired {
+			t.Fatalf("expected all overdue entries marked fired: %+v", e)
+		}
+	}
+}
+
+func TestSchedulePersistsBeforeNotifying(t *testing.T) {
+	root := t.TempDir()
+	st := Store{Entries: []Entry{
+		{ID: "a1", When: time.Now().Add(-time.Hour).Format(time.RFC3339), SourcePath: "inbox/a.md"},
+	}}
+	if err := saveStore(root, st); err != nil {
+		t.Fatal(err)
+	}
+	orig := notifierFuncs[NotifierDesktop]
+	defer func() { notifierFuncs[NotifierDesktop] = orig }()
+	notified := 0
+	notifierFuncs[NotifierDesktop] = func(context.Context, config.RemindConfig, Entry) error {
+		notified++
+		loaded, err := loadStore(root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !loaded.Entries[0].Fired {
+			t.Fatal("expected fired state persisted before notifying")
+		}
+		return nil
+	}
+	if _, err := Schedule(context.Background(), root, ScheduleOptions{Notify: true}); err != nil {
+		t.Fatal(err)
+	}
+	if notified != 1 {
+		t.Fatalf("notified=%d", notified)
+	}
+}
+
+func TestScheduleWithoutNotifyInvokesNoNotifier(t *testing.T) {
+	root := t.TempDir()
+	st := Store{Entries: []Entry{
+		{ID: "a1", When: time.Now().Add(-time.Hour).Format(time.RFC3339), SourcePath: "inbox/a.md"},
+	}}
+	if err := saveStore(root, st); err != nil {
+		t.Fatal(err)
+	}
+	calls := 0
+	saved := map[string]notifyFunc{}
+	for name, fn := range notifierFuncs {
+		saved[name] = fn
+		notifierFuncs[name] = func(context.Context, config.RemindConfig, Entry) error {
+			calls++
+			return nil
+		}
+	}
+	defer func() {
+		for name, fn := range saved {
+			notifierFuncs[name] = fn
+		}
+	}()
+	cfg := config.RemindConfig{Notifiers: []string{NotifierDesktop, NotifierCommand, NotifierWebhook}}
+	res, err := Schedule(context.Background(), root, ScheduleOptions{Config: cfg})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 0 || len(res.Notifications) != 0 || len(res.Due) != 1 {
+		t.Fatalf("calls=%d result=%+v", calls, res)
+	}
+}
+
+func TestNotifyAllCollectsPerNotifierResults(t *testing.T) {
+	orig := notifierFuncs[NotifierDesktop]
+	defer func() { notifierFuncs[NotifierDesktop] = orig }()
+	notifierFuncs[NotifierDesktop] = func(context.Context, config.RemindConfig, Entry) error {
+		return errors.New("no display")
+	}
+	cfg := config.RemindConfig{Notifiers: []string{NotifierDesktop, "pager", NotifierCommand}, Command: "true"}
+	res := notifyAll(context.Background(), cfg, Entry{ID: "a1", Message: "hi"})
+	if len(res) != 3 {
+		t.Fatalf("len=%d", len(res))
+	}
+	if res[0].OK || res[0].Error != "no display" {
+		t.Fatalf("unexpected desktop result: %+v", res[0])
+	}
+	if res[1].OK || res[1].Error == "" {
+		t.Fatalf("unexpected unknown notifier result: %+v", res[1])
+	}
+	if !res[2].OK {
+		t.Fatalf("expected command notifier to succeed: %+v", res[2])
+	}
+}
+
+func TestTestNotifyOverridesNotifier(t *testing.T) {
+	cfg := config.RemindConfig{Notifiers: []string{NotifierDesktop}, Command: "true"}
+	res := TestNotify(context.Background(), cfg, NotifierCommand, "hello")
+	if !res.OK || len(res.Notifications) != 1 || res.Notifications[0].Notifier != NotifierCommand {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	res = TestNotify(context.Background(), cfg, NotifierWebhook, "hello")
+	if res.OK || res.Notifications[0].Error == "" {
+		t.Fatalf("expected unconfigured webhook to fail: %+v", res)
+	}
+}
+
+func TestScanDedupeByMessage(t *testing.T) {
+	root := t.TempDir()
+	inbox := filepath.Join(root, "inbox")
+	if err := os.MkdirAll(inbox, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a.md", "copy.md"} {
+		if err := os.WriteFile(filepath.Join(inbox, name), []byte("REMIND[2030-01-02] pay rent\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	res, err := Scan(context.Background(), root, ScanOptions{DedupeByMessage: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Found != 2 || res.Added != 1 || res.Suppressed != 1 || res.Total != 1 {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	res, err = Scan(context.Background(), root, ScanOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Added != 1 || res.Total != 2 {
+		t.Fatalf("expected per-location behavior without dedupe: %+v", res)
+	}
+}
+
+func TestRebuildDedupeByMessageKeepsStoredEntry(t *testing.T) {
+	root := t.TempDir()
+	inbox := filepath.Join(root, "inbox")
+	if err := os.MkdirAll(inbox, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"b.md", "a.md"} {
+		if err := os.WriteFile(filepath.Join(inbox, name), []byte("REMIND[2030-01-02] pay rent\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := Scan(context.Background(), root, ScanOptions{DedupeByMessage: true}); err != nil {
+		t.Fatal(err)
+	}
+	before, err := LoadEntries(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := Rebuild(context.Background(), root, ScanOptions{DedupeByMessage: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Found != 2 || res.Kept != 1 || res.Added != 0 || res.Suppressed != 1 || res.Total != 1 {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	after, err := LoadEntries(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(before) != 1 || len(after) != 1 || after[0].ID != before[0].ID {
+		t.Fatalf("rebuild replaced the stored entry: before=%+v after=%+v", before, after)
+	}
+}
+
+func TestScanMessagelessReminderUsesDefault(t *testing.T) {
+	root := t.TempDir()
+	inbox := filepath.Join(root, "inbox")
+	if err := os.MkdirAll(inbox, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(inbox, "a.md"), []byte("REMIND[2030-01-02]\r\nREMIND[2030-01-03]   \n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	res, err := Scan(context.Background(), root, ScanOptions{DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Found != 2 || res.WouldAdd[0].Message != "Reminder from inbox/a.md" {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	res, err = Scan(context.Background(), root, ScanOptions{DryRun: true, DefaultMessage: "check {source}"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.WouldAdd[1].Message != "check inbox/a.md" {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+}
+
+func TestScanSourceFilterLimitsFiles(t *testing.T) {
+	root := t.TempDir()
+	notes := map[string]string{
+		"inbox/a.md":              "REMIND[2030-01-02] a\n",
+		"inbox/projects/x/b.md":   "REMIND[2030-01-03] b\n",
+		"scratch/current/c.md":    "REMIND[2030-01-04] c\n",
+		"inbox/projects/notes.md": "REMIND[2030-01-05] notes\n",
+	}
+	for rel, body := range notes {
+		p := filepath.Join(root, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cases := map[string]int{
+		"inbox/a.md":      1,
+		"inbox/projects":  2,
+		"inbox/projects/": 2,
+		"*/projects/*":    2,
+		"inbox/*.md":      1,
+		"slack":           0,
+	}
+	for filter, want := range cases {
+		res, err := Scan(context.Background(), root, ScanOptions{DryRun: true, SourceFilter: filter})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Found != want {
+			t.Fatalf("filter %q found %d, want %d: %+v", filter, res.Found, want, res.WouldAdd)
+		}
+	}
+	if _, err := Scan(context.Background(), root, ScanOptions{SourceFilter: "["}); err == nil {
+		t.Fatal("expected bad pattern error")
+	}
+}
+
+func TestScanDryRunDoesNotSave(t *testing.T) {
+	root := t.TempDir()
+	inbox := filepath.Join(root, "inbox")
+	if err := os.MkdirAll(inbox, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(inbox, "a.md"), []byte("REMIND[2030-01-02] pay rent\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	res, err := Scan(context.Background(), root, ScanOptions{DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.DryRun || res.Added != 1 || res.Total != 1 || len(res.WouldAdd) != 1 || res.WouldAdd[0].Message != "pay rent" {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	if _, err := os.Stat(StorePath(root)); !os.IsNotExist(err) {
+		t.Fatalf("dry run should not write the store: %v", err)
+	}
+}
+
+func TestScanPreviewIncludesSourceLines(t *testing.T) {
+	root := t.TempDir()
+	inbox := filepath.Join(root, "inbox")
+	if err := os.MkdirAll(inbox, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(inbox, "a.md"), []byte("## Bills\n- REMIND[2030-01-02] pay rent\n  landlord portal\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	res, err := Scan(context.Background(), root, ScanOptions{Preview: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Previews) != 1 {
+		t.Fatalf("unexpected previews: %+v", res.Previews)
+	}
+	pv := res.Previews[0]
+	if pv.Line != "- REMIND[2030-01-02] pay rent" || pv.Before != "## Bills" || pv.After != "landlord portal" || pv.SourceLine != 2 {
+		t.Fatalf("unexpected preview: %+v", pv)
+	}
+	res, err = Scan(context.Background(), root, ScanOptions{Preview: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Previews) != 0 {
+		t.Fatalf("expected no previews for already-known entries: %+v", res.Previews)
+	}
+}
+
+func TestRebuildPreservesFiredAndDropsStale(t *testing.T) {
+	root := t.TempDir()
+	inbox := filepath.Join(root, "inbox")
+	if err := os.MkdirAll(inbox, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(inbox, "a.md"), []byte("REMIND[2020-01-02] keep me\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Scan(context.Background(), root, ScanOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	st, err := loadStore(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	st.Entries[0].Fired = true
+	st.Entries[0].FiredAt = "2020-01-02T09:00:00Z"
+	st.Entries = append(st.Entries, Entry{ID: "stale", When: "2020-01-01T09:00:00Z", SourcePath: "inbox/gone.md"})
+	if err := saveStore(root, st); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := Rebuild(context.Background(), root, ScanOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Kept != 1 || res.Dropped != 1 || res.Added != 0 || res.Total != 1 {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	loaded, err := loadStore(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !loaded.Entries[0].Fired || loaded.Entries[0].FiredAt == "" {
+		t.Fatalf("expected fired state preserved: %+v", loaded.Entries[0])
+	}
+}
+
+func TestScheduleArchivesFiredEntries(t *testing.T) {
+	root := t.TempDir()
+	inbox := filepath.Join(root, "inbox")
+	if err := os.MkdirAll(inbox, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	note := "REMIND[2020-01-02] past\nREMIND[2999-01-02] future\n"
+	if err := os.WriteFile(filepath.Join(inbox, "a.md"), []byte(note), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Scan(context.Background(), root, ScanOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	res, err := Schedule(context.Background(), root, ScheduleOptions{Config: config.RemindConfig{ArchiveFired: true}})
 	if err != nil {
 		t.Fatal(err)
 	}
+	if len(res.Due) != 1 || res.Archived != 1 {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	active, err := LoadEntries(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(active) != 1 || active[0].Message != "future" {
+		t.Fatalf("expected only pending entry in active store: %+v", active)
+	}
+	all, err := LoadAllEntries(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 2 {
+		t.Fatalf("expected archive merged into list: %+v", all)
+	}
+	storeData, err := os.ReadFile(StorePath(root))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range all {
+		if e.Fired && strings.Contains(string(storeData), e.ID) {
+			t.Fatalf("active store still records fired reminder %s:\n%s", e.ID, storeData)
+		}
+	}
+
+	scan, err := Scan(context.Background(), root, ScanOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scan.Added != 0 {
+		t.Fatalf("archived reminder was re-added: %+v", scan)
+	}
+	archived, err := os.ReadFile(ArchivePath(root))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// With nothing new to add, a scan must not need the archive at all.
+	if err := os.WriteFile(ArchivePath(root), []byte("not json"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Scan(context.Background(), root, ScanOptions{}); err != nil {
+		t.Fatalf("scan read the archive: %v", err)
+	}
+	if err := os.WriteFile(ArchivePath(root), archived, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	rebuilt, err := Rebuild(context.Background(), root, ScanOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rebuilt.Kept != 2 || rebuilt.Archived != 0 {
+		t.Fatalf("unexpected rebuild: %+v", rebuilt)
+	}
+	for _, p := range []string{ArchivePath(root), firedIndexPath(root)} {
+		if _, err := os.Stat(p); !os.IsNotExist(err) {
+			t.Fatalf("expected %s removed after folding the archive back, stat err=%v", p, err)
+		}
+	}
+	active, err = LoadEntries(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(active) != 2 {
+		t.Fatalf("expected both entries active after rebuild: %+v", active)
+	}
+}
+
+func TestDigestGroupsOverdueWithoutFiring(t *testing.T) {
+	root := t.TempDir()
+	day1 := time.Date(2020, 1, 2, 9, 0, 0, 0, time.Local)
+	day2 := time.Date(2020, 1, 3, 9, 0, 0, 0, time.Local)
+	st := Store{Entries: []Entry{
+		{ID: "a", When: day2.Format(time.RFC3339), Message: "later"},
+		{ID: "b", When: day1.Format(time.RFC3339), Message: "first"},
+		{ID: "c", When: day1.Format(time.RFC3339), Message: "done", Fired: true},
+		{ID: "d", When: time.Now().Add(time.Hour).Format(time.RFC3339), Message: "future"},
+	}}
+	if err := saveStore(root, st); err != nil {
+		t.Fatal(err)
+	}
+	res, err := Digest(context.Background(), root, DigestOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Total != 2 || len(res.Groups) != 2 || res.Groups[0].Date != "2020-01-02" || res.Groups[0].Entries[0].ID != "b" {
+		t.Fatalf("unexpected digest: %+v", res)
+	}
+	loaded, err := loadStore(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range loaded.Entries {
+		if e.Fired && e.ID != "c" {
+			t.Fatalf("digest must not fire entries: %+v", e)
+		}
+	}
+}
+
+func TestNextReturnsEarliestPending(t *testing.T) {
+	root := t.TempDir()
+	now := time.Now()
+	st := Store{Entries: []Entry{
+		{ID: "far", When: now.Add(48 * time.Hour).Format(time.RFC3339)},
+		{ID: "soon", When: now.Add(time.Hour).Format(time.RFC3339)},
+		{ID: "old", When: now.Add(-48 * time.Hour).Format(time.RFC3339)},
+		{ID: "recent", When: now.Add(-time.Hour).Format(time.RFC3339)},
+		{ID: "fired", When: now.Add(-72 * time.Hour).Format(time.RFC3339), Fired: true},
+	}}
+	if err := saveStore(root, st); err != nil {
+		t.Fatal(err)
+	}
+	next, err := Next(context.Background(), root, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next == nil || next.ID != "soon" {
+		t.Fatalf("unexpected next: %+v", next)
+	}
+	next, err = Next(context.Background(), root, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next == nil || next.ID != "old" {
+		t.Fatalf("unexpected overdue: %+v", next)
+	}
+	empty, err := Next(context.Background(), t.TempDir(), false)
+	if err != nil || empty != nil {
+		t.Fatalf("expected no entry, got %+v err=%v", empty, err)
+	}
+}
+
+func TestValidCatchUp(t *testing.T) {
+	for _, mode := range []string{"", CatchUpFireAll, CatchUpFireLatest, CatchUpMarkSilent} {
+		if !ValidCatchUp(mode) {
+			t.Fatalf("%q should be valid", mode)
+		}
+	}
+	if ValidCatchUp("fire-some") {
+		t.Fatal("unknown mode should be invalid")
+	}
 }
@@ -0,0 +1,72 @@
+package rootio
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+const (
+	lockFileName     = "margin.lock"
+	lockPollInterval = 50 * time.Millisecond
+)
+
+var ErrLocked = errors.New("another margin process is running")
+
+type RootLock struct {
+	f *os.File
+}
+
+func Lock(root string, timeout time.Duration) (*RootLock, error) {
+	return LockNamed(root, lockFileName, timeout)
+}
+
+func LockNamed(root, name string, timeout time.Duration) (*RootLock, error) {
+	path := filepath.Join(root, "index", name)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	// The lock is an OS file lock on a file that is never removed, so it is
+	// released when the holder exits or crashes and there is no stale state to
+	// clean up (and no window where two processes both see the file as free).
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	deadline := time.Now().Add(timeout)
+	for {
+		ok, err := tryLockFile(f)
+		if err != nil {
+			_ = f.Close()
+			return nil, err
+		}
+		if ok {
+			// The PID is informational only; nothing reads it to decide ownership.
+			if err := f.Truncate(0); err == nil {
+				_, _ = f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+			}
+			return &RootLock{f: f}, nil
+		}
+		if !time.Now().Before(deadline) {
+			_ = f.Close()
+			return nil, fmt.Errorf("%w (lock %s)", ErrLocked, path)
+		}
+		time.Sleep(lockPollInterval)
+	}
+}
+
+func (l *RootLock) Release() error {
+	if l == nil || l.f == nil {
+		return nil
+	}
+	f := l.f
+	l.f = nil
+	err := unlockFile(f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,78 @@
+package rootio
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLockContention(t *testing.T) {
+	root := t.TempDir()
+	first, err := Lock(root, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Lock(root, 100*time.Millisecond); !errors.Is(err, ErrLocked) {
+		t.Fatalf("expected ErrLocked, got %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		second, err := Lock(root, 2*time.Second)
+		if err == nil {
+			err = second.Release()
+		}
+		done <- err
+	}()
+	time.Sleep(150 * time.Millisecond)
+	if err := first.Release(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("waiter did not acquire lock after release: %v", err)
+	}
+}
+
+func TestLockIgnoresLeftoverLockFile(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "index"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	// A crashed holder leaves the file behind; only a live OS lock should block.
+	if err := os.WriteFile(filepath.Join(root, "index", lockFileName), []byte("999999\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	lock, err := Lock(root, 0)
+	if err != nil {
+		t.Fatalf("leftover lock file blocked acquisition: %v", err)
+	}
+	if err := lock.Release(); err != nil {
+		t.Fatal(err)
+	}
+	if err := lock.Release(); err != nil {
+		t.Fatalf("second release: %v", err)
+	}
+}
+
+func TestLockNamedIsIndependentAndFailsFast(t *testing.T) {
+	root := t.TempDir()
+	rootLock, err := Lock(root, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = rootLock.Release() }()
+	guard, err := LockNamed(root, "schedule.lock", 0)
+	if err != nil {
+		t.Fatalf("named lock should not contend with root lock: %v", err)
+	}
+	defer func() { _ = guard.Release() }()
+	start := time.Now()
+	if _, err := LockNamed(root, "schedule.lock", 0); !errors.Is(err, ErrLocked) {
+		t.Fatalf("expected ErrLocked, got %v", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Fatal("zero timeout should not wait")
+	}
+}
@@ -1,7 +1,10 @@
 package rootio
 
 import (
+	"bufio"
+	"bytes"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -25,8 +28,52 @@ func DefaultRoot() string {
 	}
 }
 
+const AutoRoot = "auto"
+
+var rootMarkers = []string{".margin", "config.json"}
+
+func DiscoverRoot(startDir string) (string, bool) {
+	dir, err := filepath.Abs(startDir)
+	if err != nil {
+		return "", false
+	}
+	for {
+		for _, m := range rootMarkers {
+			if _, err := os.Stat(filepath.Join(dir, m)); err == nil {
+				return dir, true
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+		dir = parent
+	}
+}
+
+func ResolveRoot(root string) string {
+	if strings.TrimSpace(root) != AutoRoot {
+		return root
+	}
+	if wd, err := os.Getwd(); err == nil {
+		if found, ok := DiscoverRoot(wd); ok {
+			return found
+		}
+	}
+	return DefaultRoot()
+}
+
 func EnsureLayout(root string) error {
-	dirs := []string{
+	for _, d := range LayoutDirs(root) {
+		if err := os.MkdirAll(d, 0o755); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func LayoutDirs(root string) []string {
+	return []string{
 		filepath.Join(root, "scratch", "current"),
 		filepath.Join(root, "scratch", "history"),
 		filepath.Join(root, "inbox"),
@@ -35,12 +82,13 @@ func EnsureLayout(root string) error {
 		filepath.Join(root, "bin"),
 		filepath.Join(root, "logs"),
 	}
-	for _, d := range dirs {
-		if err := os.MkdirAll(d, 0o755); err != nil {
-			return err
-		}
+}
+
+func AtomicReplaceFile(path string, data []byte, perm os.FileMode) error {
+	if st, err := os.Stat(path); err == nil && st.Mode().IsRegular() {
+		perm = st.Mode().Perm()
 	}
-	return nil
+	return AtomicWriteFile(path, data, perm)
 }
 
 func AtomicWriteFile(path string, data []byte, perm os.FileMode) error {
@@ -80,14 +128,32 @@ func RelUnderRoot(root, p string) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	rel, err := filepath.Rel(absRoot, absP)
-	if err != nil {
-		return "", err
+	if rel, ok := relInside(absRoot, absP); ok {
+		return rel, nil
+	}
+	if rel, ok := relInside(evalSymlinksPartial(absRoot), evalSymlinksPartial(absP)); ok {
+		return rel, nil
+	}
+	return "", fmt.Errorf("path outside root")
+}
+
+func relInside(root, p string) (string, bool) {
+	rel, err := filepath.Rel(root, p)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
 	}
-	if strings.HasPrefix(rel, "..") {
-		return "", fmt.Errorf("path outside root")
+	return filepath.ToSlash(rel), true
+}
+
+func evalSymlinksPartial(p string) string {
+	if resolved, err := filepath.EvalSymlinks(p); err == nil {
+		return resolved
 	}
-	return filepath.ToSlash(rel), nil
+	parent := filepath.Dir(p)
+	if parent == p {
+		return p
+	}
+	return filepath.Join(evalSymlinksPartial(parent), filepath.Base(p))
 }
 
 func ResolvePathGroups(root string, groups []string) []string {
@@ -109,7 +175,51 @@ func ResolvePathGroups(root string, groups []string) []string {
 	return out
 }
 
-func ListFilesRecursive(paths []string) ([]string, error) {
+func WithoutHistory(paths []string) []string {
+	out := make([]string, 0, len(paths))
+	for _, p := range paths {
+		if strings.HasSuffix(filepath.ToSlash(p), "scratch/history") {
+			continue
+		}
+		out = append(out, p)
+	}
+	return out
+}
+
+func ReadPathList(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]string, 0)
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		out = append(out, line)
+	}
+	return out, nil
+}
+
+const trashDir = ".trash"
+
+func TrashPath(root string, now time.Time, name string) string {
+	return filepath.Join(root, trashDir, now.Format("2006-01-02"), now.Format("150405")+"-"+name)
+}
+
+type WalkOptions struct {
+	Hidden bool
+}
+
+func skipEntry(name string, opts WalkOptions) bool {
+	if name == trashDir {
+		return true
+	}
+	return !opts.Hidden && strings.HasPrefix(name, ".")
+}
+
+func ListFilesRecursive(paths []string, opts WalkOptions) ([]string, error) {
 	files := make([]string, 0, 128)
 	for _, root := range paths {
 		st, err := os.Stat(root)
@@ -127,6 +237,12 @@ func ListFilesRecursive(paths []string) ([]string, error) {
 			if err != nil {
 				return nil
 			}
+			if p != root && skipEntry(d.Name(), opts) {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
 			if d.IsDir() {
 				return nil
 			}
@@ -141,6 +257,22 @@ func ListFilesRecursive(paths []string) ([]string, error) {
 	return files, nil
 }
 
+const binarySniffSize = 8000
+
+func IsBinaryData(data []byte) bool {
+	if len(data) > binarySniffSize {
+		data = data[:binarySniffSize]
+	}
+	return bytes.IndexByte(data, 0) >= 0
+}
+
+func SniffBinary(r io.Reader) (io.Reader, bool) {
+	// Callers must read from the returned reader; the peeked bytes live in its buffer.
+	br := bufio.NewReaderSize(r, binarySniffSize)
+	data, _ := br.Peek(binarySniffSize)
+	return br, IsBinaryData(data)
+}
+
 func TimestampSlug(t time.Time) string {
 	return t.Format("20060102T150405")
 }
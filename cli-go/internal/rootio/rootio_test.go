@@ -0,0 +1,168 @@
+package rootio
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestReadPathListSkipsBlanksAndComments(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "scope.txt")
+	if err := os.WriteFile(p, []byte("# project a\n inbox \r\n\nslack\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadPathList(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0] != "inbox" || got[1] != "slack" {
+		t.Fatalf("got %q", got)
+	}
+}
+
+func TestDiscoverRootWalksUpToMarker(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, ".margin"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	nested := filepath.Join(root, "a", "b")
+	if err := os.MkdirAll(nested, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	got, ok := DiscoverRoot(nested)
+	if !ok {
+		t.Fatal("expected root to be discovered")
+	}
+	want, _ := filepath.Abs(root)
+	if got != want {
+		t.Fatalf("got %q want %q", got, want)
+	}
+}
+
+func TestResolveRootPassesThroughExplicitRoot(t *testing.T) {
+	if got := ResolveRoot("/tmp/explicit"); got != "/tmp/explicit" {
+		t.Fatalf("got %q", got)
+	}
+}
+
+func TestListFilesRecursiveSkipsHiddenByDefault(t *testing.T) {
+	root := t.TempDir()
+	for _, rel := range []string{"a.md", ".hidden.md", ".notes/b.md", ".trash/c.md"} {
+		p := filepath.Join(root, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte("x"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files, err := ListFilesRecursive([]string{root}, WalkOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || filepath.Base(files[0]) != "a.md" {
+		t.Fatalf("unexpected default files: %v", files)
+	}
+
+	files, err = ListFilesRecursive([]string{root}, WalkOptions{Hidden: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 3 {
+		t.Fatalf("expected hidden files without .trash, got %v", files)
+	}
+}
+
+func TestRelUnderRootSymlinkedRoot(t *testing.T) {
+	base, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	real := filepath.Join(base, "vault")
+	if err := os.MkdirAll(filepath.Join(real, "inbox"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(base, "link")
+	if err := os.Symlink(real, link); err != nil {
+		t.Skipf("symlinks unavailable: %v", err)
+	}
+	cases := []string{
+		filepath.Join(real, "inbox", "a.md"),
+		filepath.Join(link, "inbox", "a.md"),
+		filepath.Join(real, "inbox", "new", "b.md"),
+	}
+	want := []string{"inbox/a.md", "inbox/a.md", "inbox/new/b.md"}
+	for i, p := range cases {
+		got, err := RelUnderRoot(link, p)
+		if err != nil || got != want[i] {
+			t.Fatalf("RelUnderRoot(link, %s) = %q, %v; want %q", p, got, err, want[i])
+		}
+	}
+	if _, err := RelUnderRoot(link, filepath.Join(base, "other.md")); err == nil {
+		t.Fatal("expected path outside root to be rejected")
+	}
+}
+
+func TestWithoutHistoryDropsOnlyScratchHistory(t *testing.T) {
+	root := t.TempDir()
+	got := WithoutHistory(ResolvePathGroups(root, []string{"scratch", "inbox"}))
+	want := []string{filepath.Join(root, "scratch", "current"), filepath.Join(root, "inbox")}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Fatalf("got %v want %v", got, want)
+	}
+}
+
+func TestAtomicReplaceFilePreservesMode(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "note.md")
+	if err := os.WriteFile(path, []byte("old\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(path, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := AtomicReplaceFile(path, []byte("new\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	st, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runtime.GOOS != "windows" && st.Mode().Perm() != 0o600 {
+		t.Fatalf("mode=%v, want 0600", st.Mode().Perm())
+	}
+	fresh := filepath.Join(filepath.Dir(path), "fresh.md")
+	if err := AtomicReplaceFile(fresh, []byte("x"), 0o640); err != nil {
+		t.Fatal(err)
+	}
+	st, err = os.Stat(fresh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runtime.GOOS != "windows" && st.Mode().Perm() != 0o640 {
+		t.Fatalf("fresh file mode=%v, want 0640", st.Mode().Perm())
+	}
+	if data, _ := os.ReadFile(path); string(data) != "new\n" {
+		t.Fatalf("content=%q", data)
+	}
+}
+
+func TestSniffBinaryKeepsPeekedBytes(t *testing.T) {
+	r, binary := SniffBinary(strings.NewReader("plain text\n"))
+	if binary {
+		t.Fatal("text reported as binary")
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "plain text\n" {
+		t.Fatalf("data=%q", data)
+	}
+	if _, binary := SniffBinary(strings.NewReader("PK\x00\x03")); !binary {
+		t.Fatal("NUL byte not reported as binary")
+	}
+}
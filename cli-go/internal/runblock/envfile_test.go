@@ -0,0 +1,51 @@
+package runblock
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"margin/internal/config"
+)
+
+func TestParseEnvHandlesQuotesAndExport(t *testing.T) {
+	in := "# secrets\nexport TOKEN=abc123\nNAME=\"hello world\"\nRAW='a $b \\n'\nMULTI=\"x\\ny\"\nPLAIN=value # comment\nEMPTY=\n"
+	got, err := ParseEnv(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"TOKEN=abc123", "NAME=hello world", `RAW=a $b \n`, "MULTI=x\ny", "PLAIN=value", "EMPTY="}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %q want %q", got, want)
+	}
+	if _, err := ParseEnv("not a pair\n"); err == nil {
+		t.Fatal("expected error for malformed line")
+	}
+	if _, err := ParseEnv("A=\"open\n"); err == nil {
+		t.Fatal("expected error for unterminated quote")
+	}
+}
+
+func TestRunWithEnvPassesVariablesToShell(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+	path := filepath.Join(t.TempDir(), "note.md")
+	if err := os.WriteFile(path, []byte("```sh\necho \"$MARGIN_TEST_SECRET\"\n```\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	res, err := RunWithOptions(context.Background(), path, 0, config.RunBlockConfig{Shell: "sh"}, RunOptions{Env: []string{"MARGIN_TEST_SECRET=s3cret"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(res.Output) != "s3cret" {
+		t.Fatalf("unexpected output: %q", res.Output)
+	}
+	if res.Code != "" {
+		t.Fatalf("code should be omitted without EchoCode: %q", res.Code)
+	}
+}
@@ -0,0 +1,119 @@
+package runblock
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/google/jsonschema-go/jsonschema"
+)
+
+func validateJSON(code, noteDir string) (string, int) {
+	var doc any
+	if err := json.Unmarshal([]byte(code), &doc); err != nil {
+		return err.Error(), 1
+	}
+	obj, ok := doc.(map[string]any)
+	if !ok || obj["$schema"] == nil {
+		return "no $schema reference or inline schema in JSON object", 1
+	}
+	schema, err := loadSchema(obj["$schema"], noteDir)
+	if err != nil {
+		return err.Error(), 1
+	}
+	instance := make(map[string]any, len(obj))
+	for k, v := range obj {
+		if k != "$schema" {
+			instance[k] = v
+		}
+	}
+	resolved, err := schema.Resolve(nil)
+	if err != nil {
+		return fmt.Sprintf("invalid schema: %v", err), 1
+	}
+	if err := resolved.Validate(instance); err != nil {
+		ptr, msg := locateFailure(schema, instance, "", err)
+		if ptr == "" {
+			ptr = "/"
+		}
+		return fmt.Sprintf("%s: %s\n", ptr, msg), 1
+	}
+	return "valid\n", 0
+}
+
+func loadSchema(ref any, noteDir string) (*jsonschema.Schema, error) {
+	var data []byte
+	switch v := ref.(type) {
+	case string:
+		if strings.Contains(v, "://") {
+			return nil, fmt.Errorf("remote schema %q is not supported; use a local path or inline schema", v)
+		}
+		p := v
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(noteDir, p)
+		}
+		b, err := os.ReadFile(p)
+		if err != nil {
+			return nil, fmt.Errorf("read schema: %w", err)
+		}
+		data = b
+	case map[string]any:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		data = b
+	default:
+		return nil, errors.New("$schema must be a path or an inline schema object")
+	}
+	var schema jsonschema.Schema
+	if err := json.Unmarshal(data, &schema); err != nil {
+		return nil, fmt.Errorf("parse schema: %w", err)
+	}
+	return &schema, nil
+}
+
+func locateFailure(schema *jsonschema.Schema, instance any, ptr string, err error) (string, string) {
+	switch v := instance.(type) {
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			sub := schema.Properties[k]
+			if sub == nil {
+				continue
+			}
+			if subErr := validateStandalone(sub, v[k]); subErr != nil {
+				return locateFailure(sub, v[k], ptr+"/"+escapePointer(k), subErr)
+			}
+		}
+	case []any:
+		if schema.Items != nil {
+			for i, item := range v {
+				if subErr := validateStandalone(schema.Items, item); subErr != nil {
+					return locateFailure(schema.Items, item, fmt.Sprintf("%s/%d", ptr, i), subErr)
+				}
+			}
+		}
+	}
+	return ptr, strings.TrimPrefix(err.Error(), "validating root: ")
+}
+
+func validateStandalone(schema *jsonschema.Schema, instance any) error {
+	resolved, err := schema.Resolve(nil)
+	if err != nil {
+		return nil
+	}
+	return resolved.Validate(instance)
+}
+
+func escapePointer(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "~", "~0"), "/", "~1")
+}
@@ -0,0 +1,52 @@
+package runblock
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"margin/internal/config"
+)
+
+func TestValidateJSONInlineSchema(t *testing.T) {
+	schema := `{"type":"object","required":["name"],"properties":{"name":{"type":"string"},"tags":{"type":"array","items":{"type":"string"}}}}`
+	out, code := validateJSON(`{"$schema":`+schema+`,"name":"x","tags":["a","b"]}`, ".")
+	if code != 0 || out != "valid\n" {
+		t.Fatalf("expected valid, got %d %q", code, out)
+	}
+	out, code = validateJSON(`{"$schema":`+schema+`,"name":"x","tags":["a",3]}`, ".")
+	if code != 1 || !strings.HasPrefix(out, "/tags/1: ") {
+		t.Fatalf("expected pointer to bad item, got %d %q", code, out)
+	}
+	out, code = validateJSON(`{"$schema":`+schema+`,"tags":[]}`, ".")
+	if code != 1 || !strings.HasPrefix(out, "/: ") || !strings.Contains(out, "name") {
+		t.Fatalf("expected root-level required error, got %d %q", code, out)
+	}
+}
+
+func TestRunValidateOnlyUsesSchemaFile(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "person.schema.json"), []byte(`{"type":"object","properties":{"age":{"type":"integer","minimum":0}}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	note := filepath.Join(dir, "note.md")
+	if err := os.WriteFile(note, []byte("```json\n{\"$schema\": \"person.schema.json\", \"age\": -1}\n```\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	res, err := RunWithOptions(context.Background(), note, 0, config.RunBlockConfig{}, RunOptions{ValidateOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.ExitCode != 1 || !strings.HasPrefix(res.Output, "/age: ") {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	res, err = Run(context.Background(), note, 0, config.RunBlockConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.ExitCode != 0 || !strings.Contains(res.Output, "\"age\": -1") {
+		t.Fatalf("expected default pretty-print, got %+v", res)
+	}
+}
@@ -8,8 +8,8 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"runtime"
-	"sort"
 	"strings"
 	"time"
 
@@ -23,6 +23,11 @@ import (
 
 const executionTimeout = 30 * time.Second
 
+const (
+	TieBreakNext     = "next"
+	TieBreakPrevious = "previous"
+)
+
 type Block struct {
 	Language     string
 	Code         string
@@ -39,9 +44,20 @@ type Result struct {
 	ExitCode int    `json:"exit_code"`
 	RanAt    string `json:"ran_at"`
 	BlockEnd int    `json:"block_end"`
+	Code     string `json:"code,omitempty"`
+}
+
+type RunOptions struct {
+	Env          []string
+	EchoCode     bool
+	ValidateOnly bool
 }
 
 func Run(ctx context.Context, filePath string, cursor int, cfg config.RunBlockConfig) (Result, error) {
+	return RunWithOptions(ctx, filePath, cursor, cfg, RunOptions{})
+}
+
+func RunWithOptions(ctx context.Context, filePath string, cursor int, cfg config.RunBlockConfig, opts RunOptions) (Result, error) {
 	if err := ctx.Err(); err != nil {
 		return Result{}, err
 	}
@@ -49,27 +65,41 @@ func Run(ctx context.Context, filePath string, cursor int, cfg config.RunBlockCo
 	if err != nil {
 		return Result{}, err
 	}
-	blocks := ParseBlocks(string(b))
+	blocks := ParseBlocksWithIndented(string(b), cfg.IndentedLanguage)
 	if len(blocks) == 0 {
 		return Result{}, errors.New("no fenced code block found")
 	}
-	block := PickBlock(blocks, cursor)
+	if !ValidTieBreak(cfg.TieBreak) {
+		return Result{}, fmt.Errorf("invalid runblock.tie_break: %s", cfg.TieBreak)
+	}
+	block := PickBlockIn(string(b), blocks, cursor, cfg.TieBreak)
 	if block == nil {
 		return Result{}, errors.New("unable to select code block")
 	}
 
 	lang := strings.ToLower(block.Language)
 	res := Result{Language: lang, RanAt: time.Now().Format(time.RFC3339), BlockEnd: block.End}
+	if opts.EchoCode {
+		res.Code = block.Code
+	}
+	env := opts.Env
+	if opts.ValidateOnly && lang != "json" {
+		return Result{}, fmt.Errorf("validate-only applies to json blocks, got %s", block.Language)
+	}
 	switch lang {
 	case "bash", "sh", "shell":
-		output, code := runShell(ctx, block.Code, cfg.Shell)
+		output, code := runShell(ctx, block.Code, cfg.Shell, env)
 		res.Output = output
 		res.ExitCode = code
 	case "python", "py":
-		output, code := runPython(ctx, block.Code, cfg.PythonBin)
+		output, code := runPython(ctx, block.Code, cfg.PythonBin, env)
 		res.Output = output
 		res.ExitCode = code
 	case "json":
+		if opts.ValidateOnly {
+			res.Output, res.ExitCode = validateJSON(block.Code, filepath.Dir(filePath))
+			break
+		}
 		pretty, err := prettyJSON(block.Code)
 		if err != nil {
 			res.Output = err.Error()
@@ -82,16 +112,25 @@ func Run(ctx context.Context, filePath string, cursor int, cfg config.RunBlockCo
 		if strings.TrimSpace(cfg.SQLCmd) == "" {
 			return Result{}, errors.New("sql execution unsupported without runblock.sql_cmd")
 		}
-		output, code := runWithCmd(ctx, cfg.SQLCmd, block.Code)
+		output, code := runWithCmd(ctx, cfg.SQLCmd, block.Code, env)
 		res.Output = output
 		res.ExitCode = code
 	default:
-		return Result{}, fmt.Errorf("unsupported language: %s", block.Language)
+		if !cfg.AllowShebang || !strings.HasPrefix(block.Code, "#!") {
+			return Result{}, fmt.Errorf("unsupported language: %s", block.Language)
+		}
+		output, code := runShebang(ctx, block.Code, env)
+		res.Output = output
+		res.ExitCode = code
 	}
 	return res, nil
 }
 
 func ParseBlocks(s string) []Block {
+	return ParseBlocksWithIndented(s, "")
+}
+
+func ParseBlocksWithIndented(s, indentedLang string) []Block {
 	src := []byte(s)
 	doc := goldmark.New().Parser().Parse(text.NewReader(src))
 	blocks := make([]Block, 0)
@@ -99,6 +138,10 @@ func ParseBlocks(s string) []Block {
 		if !entering {
 			return ast.WalkContinue, nil
 		}
+		if cb, ok := n.(*ast.CodeBlock); ok && indentedLang != "" {
+			blocks = append(blocks, indentedBlock(src, cb, indentedLang))
+			return ast.WalkContinue, nil
+		}
 		fb, ok := n.(*ast.FencedCodeBlock)
 		if !ok {
 			return ast.WalkContinue, nil
@@ -134,6 +177,52 @@ func ParseBlocks(s string) []Block {
 	return blocks
 }
 
+func indentedBlock(src []byte, cb *ast.CodeBlock, lang string) Block {
+	lines := cb.Lines()
+	code := strings.TrimSuffix(string(lines.Value(src)), "\n")
+	codeStart := 0
+	codeEnd := 0
+	if lines.Len() > 0 {
+		codeStart = lines.At(0).Start
+		codeEnd = lines.At(lines.Len() - 1).Stop
+	}
+	start := codeStart
+	for start > 0 && src[start-1] != '\n' {
+		start--
+	}
+	return Block{
+		Language:  lang,
+		Code:      code,
+		Start:     start,
+		End:       codeEnd,
+		CodeStart: codeStart,
+		CodeEnd:   codeEnd,
+	}
+}
+
+type BlockInfo struct {
+	Index     int    `json:"index"`
+	Language  string `json:"language"`
+	Start     int    `json:"start"`
+	End       int    `json:"end"`
+	FirstLine string `json:"first_line"`
+}
+
+func Describe(blocks []Block) []BlockInfo {
+	out := make([]BlockInfo, 0, len(blocks))
+	for i, b := range blocks {
+		first, _, _ := strings.Cut(b.Code, "\n")
+		out = append(out, BlockInfo{
+			Index:     i,
+			Language:  b.Language,
+			Start:     b.Start,
+			End:       b.End,
+			FirstLine: strings.TrimSpace(strings.TrimSuffix(first, "\r")),
+		})
+	}
+	return out
+}
+
 func findOpeningFenceStart(src []byte, codeStart int) int {
 	if codeStart <= 0 {
 		return 0
@@ -198,36 +287,60 @@ func isFenceLine(line string) bool {
 	return strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~")
 }
 
-func PickBlock(blocks []Block, cursor int) *Block {
-	if len(blocks) == 0 {
-		return nil
+func ValidTieBreak(tieBreak string) bool {
+	switch tieBreak {
+	case "", TieBreakNext, TieBreakPrevious:
+		return true
+	default:
+		return false
 	}
+}
+
+func PickBlock(blocks []Block, cursor int) *Block {
+	return PickBlockIn("", blocks, cursor, TieBreakNext)
+}
+
+func PickBlockIn(src string, blocks []Block, cursor int, tieBreak string) *Block {
+	var prev, next *Block
 	for i := range blocks {
-		if cursor >= blocks[i].Start && cursor <= blocks[i].End {
-			return &blocks[i]
+		b := &blocks[i]
+		if cursor >= b.Start && cursor <= b.End {
+			return b
 		}
-	}
-	type candidate struct {
-		idx  int
-		dist int
-	}
-	cands := make([]candidate, 0, len(blocks))
-	for i := range blocks {
-		d := blocks[i].Start - cursor
-		if d < 0 {
-			d = -d
+		if b.End < cursor && (prev == nil || b.End > prev.End) {
+			prev = b
+		}
+		if b.Start > cursor && (next == nil || b.Start < next.Start) {
+			next = b
 		}
-		cands = append(cands, candidate{idx: i, dist: d})
 	}
-	sort.Slice(cands, func(i, j int) bool { return cands[i].dist < cands[j].dist })
-	return &blocks[cands[0].idx]
+	switch {
+	case prev == nil:
+		return next
+	case next == nil:
+		return prev
+	}
+	if src != "" && prev.End <= next.Start && next.Start <= len(src) && strings.TrimSpace(src[prev.End:next.Start]) == "" {
+		return next
+	}
+	before, after := cursor-prev.End, next.Start-cursor
+	switch {
+	case after < before:
+		return next
+	case before < after:
+		return prev
+	case tieBreak == TieBreakPrevious:
+		return prev
+	default:
+		return next
+	}
 }
 
-func runShell(ctx context.Context, code, shell string) (string, int) {
+func runShell(ctx context.Context, code, shell string, env []string) (string, int) {
 	candidates := shellCandidates(shell)
 	lastErr := ""
 	for _, sh := range candidates {
-		output, exitCode, err := runShellWithBinary(ctx, sh, code)
+		output, exitCode, err := runShellWithBinary(ctx, sh, code, env)
 		if err == nil {
 			return output, exitCode
 		}
@@ -243,7 +356,7 @@ func runShell(ctx context.Context, code, shell string) (string, int) {
 	return lastErr, 1
 }
 
-func runShellWithBinary(ctx context.Context, shell, code string) (string, int, error) {
+func runShellWithBinary(ctx context.Context, shell, code string, env []string) (string, int, error) {
 	s := strings.TrimSpace(shell)
 	if s == "" {
 		return "", 1, errors.New("empty shell")
@@ -260,6 +373,7 @@ func runShellWithBinary(ctx context.Context, shell, code string) (string, int, e
 	default:
 		cmd = exec.CommandContext(timeoutCtx, s, "-lc", code)
 	}
+	cmd.Env = childEnv(env)
 	var out bytes.Buffer
 	cmd.Stdout = &out
 	cmd.Stderr = &out
@@ -280,6 +394,15 @@ func runShellWithBinary(ctx context.Context, shell, code string) (string, int, e
 	return out.String(), 1, err
 }
 
+func CheckShell(shell string) error {
+	s := strings.TrimSpace(shell)
+	if s == "" {
+		return errors.New("empty shell")
+	}
+	_, err := exec.LookPath(s)
+	return err
+}
+
 func shellCandidates(configured string) []string {
 	out := make([]string, 0, 8)
 	if strings.TrimSpace(configured) != "" {
@@ -324,50 +447,57 @@ func isNotFoundErr(err error) bool {
 	return false
 }
 
-func runPython(ctx context.Context, code, pythonBin string) (string, int) {
+func runPython(ctx context.Context, code, pythonBin string, env []string) (string, int) {
 	if strings.TrimSpace(pythonBin) == "" {
 		pythonBin = "python"
 	}
-	tmp, err := os.CreateTemp("", "margin-run-*.py")
+	tmpName, err := writeScript("margin-run-*.py", code, 0o600)
 	if err != nil {
 		return err.Error(), 1
 	}
-	tmpName := tmp.Name()
 	defer func() {
 		_ = os.Remove(tmpName)
 	}()
-	if _, err := tmp.WriteString(code); err != nil {
-		_ = tmp.Close()
-		return err.Error(), 1
+	return runCommand(ctx, pythonBin, []string{tmpName}, "", env)
+}
+
+func runShebang(ctx context.Context, code string, env []string) (string, int) {
+	first, _, _ := strings.Cut(code, "\n")
+	parts := strings.Fields(strings.TrimSuffix(strings.TrimPrefix(first, "#!"), "\r"))
+	if len(parts) == 0 {
+		return "invalid shebang", 1
 	}
-	if err := tmp.Close(); err != nil {
+	tmpName, err := writeScript("margin-run-*", code, 0o700)
+	if err != nil {
 		return err.Error(), 1
 	}
+	defer func() {
+		_ = os.Remove(tmpName)
+	}()
+	return runCommand(ctx, parts[0], append(parts[1:], tmpName), "", env)
+}
 
-	timeoutCtx, cancel := context.WithTimeout(ctx, executionTimeout)
-	defer cancel()
-	cmd := exec.CommandContext(timeoutCtx, pythonBin, tmpName)
-	var out bytes.Buffer
-	cmd.Stdout = &out
-	cmd.Stderr = &out
-	err = cmd.Run()
-	if err == nil {
-		return out.String(), 0
+func writeScript(pattern, code string, mode os.FileMode) (string, error) {
+	tmp, err := os.CreateTemp("", pattern)
+	if err != nil {
+		return "", err
 	}
-	if errors.Is(timeoutCtx.Err(), context.DeadlineExceeded) {
-		return out.String() + "\ncommand timed out", 124
+	name := tmp.Name()
+	_, err = tmp.WriteString(code)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
 	}
-	if errors.Is(timeoutCtx.Err(), context.Canceled) {
-		return out.String() + "\ncommand canceled", 130
+	if err == nil {
+		err = os.Chmod(name, mode)
 	}
-	var ee *exec.ExitError
-	if errors.As(err, &ee) {
-		return out.String(), ee.ExitCode()
+	if err != nil {
+		_ = os.Remove(name)
+		return "", err
 	}
-	return out.String() + "\n" + err.Error(), 1
+	return name, nil
 }
 
-func runWithCmd(ctx context.Context, command, input string) (string, int) {
+func runWithCmd(ctx context.Context, command, input string, env []string) (string, int) {
 	parts, err := shlex.Split(command)
 	if err != nil {
 		return "invalid command: " + err.Error(), 1
@@ -375,14 +505,21 @@ func runWithCmd(ctx context.Context, command, input string) (string, int) {
 	if len(parts) == 0 {
 		return "invalid command", 1
 	}
+	return runCommand(ctx, parts[0], parts[1:], input, env)
+}
+
+func runCommand(ctx context.Context, name string, args []string, input string, env []string) (string, int) {
 	timeoutCtx, cancel := context.WithTimeout(ctx, executionTimeout)
 	defer cancel()
-	cmd := exec.CommandContext(timeoutCtx, parts[0], parts[1:]...)
-	cmd.Stdin = strings.NewReader(input)
+	cmd := exec.CommandContext(timeoutCtx, name, args...)
+	if input != "" {
+		cmd.Stdin = strings.NewReader(input)
+	}
+	cmd.Env = childEnv(env)
 	var out bytes.Buffer
 	cmd.Stdout = &out
 	cmd.Stderr = &out
-	err = cmd.Run()
+	err := cmd.Run()
 	if err == nil {
 		return out.String(), 0
 	}
@@ -1,6 +1,15 @@
 package runblock
 
-import "testing"
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"margin/internal/config"
+)
 
 func TestParseBlocksAndPick(t *testing.T) {
 	in := "before\n```python\nprint('x')\n```\nafter\n"
@@ -38,3 +47,122 @@ func TestParseBlocksTildeFence(t *testing.T) {
 		t.Fatalf("unexpected language: %s", blocks[0].Language)
 	}
 }
+
+func TestParseBlocksIndentedOptIn(t *testing.T) {
+	in := "intro\n\n    echo one\n    echo two\n\n```sh\necho fenced\n```\n"
+	if blocks := ParseBlocks(in); len(blocks) != 1 || blocks[0].Code != "echo fenced" {
+		t.Fatalf("expected fenced-only by default, got %+v", blocks)
+	}
+	blocks := ParseBlocksWithIndented(in, "sh")
+	if len(blocks) != 2 {
+		t.Fatalf("expected 2 blocks, got %+v", blocks)
+	}
+	ib := blocks[0]
+	if ib.Language != "sh" || ib.Code != "echo one\necho two" {
+		t.Fatalf("unexpected indented block: %+v", ib)
+	}
+	if ib.Start != strings.Index(in, "    echo one") || ib.End != strings.Index(in, "echo two\n")+len("echo two\n") {
+		t.Fatalf("unexpected offsets: start=%d end=%d", ib.Start, ib.End)
+	}
+	if picked := PickBlock(blocks, ib.Start+6); picked == nil || picked.Code != ib.Code {
+		t.Fatalf("expected cursor inside indented block to pick it, got %+v", picked)
+	}
+}
+
+func TestDescribeListsBlocksWithoutRunning(t *testing.T) {
+	in := "```sh\necho one\necho two\n```\n\n```\n\n```\n"
+	infos := Describe(ParseBlocks(in))
+	if len(infos) != 2 {
+		t.Fatalf("expected 2 blocks, got %+v", infos)
+	}
+	if infos[0].Index != 0 || infos[0].Language != "sh" || infos[0].FirstLine != "echo one" || infos[0].Start != 0 {
+		t.Fatalf("unexpected first block: %+v", infos[0])
+	}
+	if infos[1].Index != 1 || infos[1].Language != "" || infos[1].FirstLine != "" || infos[1].Start < infos[0].End {
+		t.Fatalf("unexpected second block: %+v", infos[1])
+	}
+}
+
+func TestRunShebangRequiresOptIn(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+	path := filepath.Join(t.TempDir(), "note.md")
+	if err := os.WriteFile(path, []byte("```custom\n#!/bin/sh\necho from-shebang\n```\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Run(context.Background(), path, 0, config.RunBlockConfig{}); err == nil {
+		t.Fatal("expected unsupported language without allow_shebang")
+	}
+	res, err := Run(context.Background(), path, 0, config.RunBlockConfig{AllowShebang: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.ExitCode != 0 || strings.TrimSpace(res.Output) != "from-shebang" {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+}
+
+func TestRunEchoCode(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "note.md")
+	if err := os.WriteFile(path, []byte("```json\n{\"a\":1}\n```\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	res, err := RunWithOptions(context.Background(), path, 0, config.RunBlockConfig{}, RunOptions{EchoCode: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Code != `{"a":1}` || res.ExitCode != 0 {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+}
+
+func TestCheckShellAndCandidateOrder(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+	if err := CheckShell("sh"); err != nil {
+		t.Fatalf("sh should resolve: %v", err)
+	}
+	if err := CheckShell("margin-no-such-shell"); err == nil {
+		t.Fatal("expected missing shell to be rejected")
+	}
+	if err := CheckShell("  "); err == nil {
+		t.Fatal("expected empty shell to be rejected")
+	}
+	got := shellCandidates("fish")
+	if len(got) < 3 || got[0] != "fish" || got[1] != "bash" || got[2] != "sh" {
+		t.Fatalf("override should be tried first: %v", got)
+	}
+}
+
+func TestPickBlockBetweenBlocks(t *testing.T) {
+	src := "```sh\necho a\n```\nxxxxx\n```sh\necho b\n```\n"
+	blocks := ParseBlocks(src)
+	if len(blocks) != 2 {
+		t.Fatalf("expected 2 blocks, got %d", len(blocks))
+	}
+	a, b := &blocks[0], &blocks[1]
+	mid := (a.End + b.Start) / 2
+	if (mid-a.End) != (b.Start-mid) || strings.TrimSpace(src[a.End:b.Start]) == "" {
+		t.Fatalf("test layout should put the cursor equidistant over prose: a.End=%d b.Start=%d", a.End, b.Start)
+	}
+	if got := PickBlockIn(src, blocks, mid, ""); got != b {
+		t.Fatalf("default tie-break should pick the next block, got %+v", got)
+	}
+	if got := PickBlockIn(src, blocks, mid, TieBreakPrevious); got != a {
+		t.Fatalf("previous tie-break should pick the earlier block, got %+v", got)
+	}
+	if got := PickBlockIn(src, blocks, a.End+1, TieBreakNext); got != a {
+		t.Fatalf("nearer previous block should win regardless of tie-break, got %+v", got)
+	}
+
+	spaced := "```sh\necho a\n```\n\n\n\n```sh\necho b\n```\n"
+	blocks = ParseBlocks(spaced)
+	if got := PickBlockIn(spaced, blocks, blocks[0].End+1, TieBreakPrevious); got != &blocks[1] {
+		t.Fatalf("whitespace-only gap should pick the following block, got %+v", got)
+	}
+	if got := PickBlockIn(spaced, blocks, len(spaced), ""); got != &blocks[1] {
+		t.Fatalf("cursor after the last block should pick it, got %+v", got)
+	}
+}
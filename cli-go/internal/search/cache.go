@@ -0,0 +1,110 @@
+package search
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"margin/internal/rootio"
+)
+
+const defaultCacheSize = 32
+
+type cacheEntry struct {
+	key     string
+	results []Result
+	stored  time.Time
+}
+
+type Cache struct {
+	mu    sync.Mutex
+	ttl   time.Duration
+	size  int
+	ll    *list.List
+	items map[string]*list.Element
+}
+
+func NewCache(size int, ttl time.Duration) *Cache {
+	if size <= 0 {
+		size = defaultCacheSize
+	}
+	return &Cache{
+		ttl:   ttl,
+		size:  size,
+		ll:    list.New(),
+		items: map[string]*list.Element{},
+	}
+}
+
+func (c *Cache) Run(ctx context.Context, root, query string, groups []string, limit int, opts Options) ([]Result, error) {
+	if c == nil || c.ttl <= 0 {
+		return Run(ctx, root, query, groups, limit, opts)
+	}
+	stamp := maxDirMtime(rootio.ResolvePathGroups(root, groups))
+	key := fmt.Sprintf("%s\x00%s\x00%q\x00%d\x00%+v\x00%d", root, query, groups, limit, opts, stamp.UnixNano())
+	if res, ok := c.get(key); ok {
+		return res, nil
+	}
+	res, err := Run(ctx, root, query, groups, limit, opts)
+	if err != nil {
+		return nil, err
+	}
+	c.put(key, res)
+	return append([]Result(nil), res...), nil
+}
+
+func (c *Cache) get(key string) ([]Result, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	ent := el.Value.(*cacheEntry)
+	if time.Since(ent.stored) > c.ttl {
+		c.ll.Remove(el)
+		delete(c.items, key)
+		return nil, false
+	}
+	c.ll.MoveToFront(el)
+	return append([]Result(nil), ent.results...), true
+}
+
+func (c *Cache) put(key string, res []Result) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok {
+		c.ll.Remove(el)
+	}
+	c.items[key] = c.ll.PushFront(&cacheEntry{key: key, results: res, stored: time.Now()})
+	for c.ll.Len() > c.size {
+		last := c.ll.Back()
+		c.ll.Remove(last)
+		delete(c.items, last.Value.(*cacheEntry).key)
+	}
+}
+
+func maxDirMtime(paths []string) time.Time {
+	var latest time.Time
+	for _, p := range paths {
+		_ = filepath.WalkDir(p, func(_ string, d os.DirEntry, err error) error {
+			if err != nil || !d.IsDir() {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				return fs.SkipDir
+			}
+			if info.ModTime().After(latest) {
+				latest = info.ModTime()
+			}
+			return nil
+		})
+	}
+	return latest
+}
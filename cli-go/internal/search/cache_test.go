@@ -0,0 +1,53 @@
+package search
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCacheReturnsCachedUntilDirectoryChanges(t *testing.T) {
+	root := t.TempDir()
+	inbox := filepath.Join(root, "inbox")
+	if err := os.MkdirAll(inbox, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	note := filepath.Join(inbox, "a.md")
+	if err := os.WriteFile(note, []byte("needle\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	c := NewCache(4, time.Minute)
+	first, err := c.Run(context.Background(), root, "needle", []string{"inbox"}, 10, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(first) != 1 {
+		t.Fatalf("expected 1 result, got %d", len(first))
+	}
+
+	// Rewriting in place keeps the directory mtime, so the cached result is served.
+	if err := os.WriteFile(note, []byte("nothing\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cached, err := c.Run(context.Background(), root, "needle", []string{"inbox"}, 10, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cached) != 1 {
+		t.Fatalf("expected cached result, got %d", len(cached))
+	}
+
+	future := time.Now().Add(time.Hour)
+	if err := os.Chtimes(inbox, future, future); err != nil {
+		t.Fatal(err)
+	}
+	fresh, err := c.Run(context.Background(), root, "needle", []string{"inbox"}, 10, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fresh) != 0 {
+		t.Fatalf("expected invalidated result, got %d", len(fresh))
+	}
+}
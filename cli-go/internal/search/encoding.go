@@ -0,0 +1,68 @@
+package search
+
+import (
+	"errors"
+	"io"
+	"os"
+	"strings"
+
+	"margin/internal/rootio"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/transform"
+)
+
+func ValidEncoding(name string) bool {
+	_, err := lookupEncoding(name)
+	return err == nil
+}
+
+func lookupEncoding(name string) (encoding.Encoding, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", "utf-8", "utf8":
+		return nil, nil
+	}
+	return htmlindex.Get(name)
+}
+
+type textFile struct {
+	io.Reader
+	io.Closer
+}
+
+var errBinaryFile = errors.New("binary file")
+
+func openText(path, enc string, skipBinary bool) (io.ReadCloser, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	e, err := lookupEncoding(enc)
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	var r io.Reader = f
+	if e != nil {
+		r = transform.NewReader(r, e.NewDecoder())
+	}
+	if skipBinary {
+		// Sniff the decoded text: encodings such as UTF-16 put NUL bytes in every note.
+		var binary bool
+		if r, binary = rootio.SniffBinary(r); binary {
+			_ = f.Close()
+			return nil, errBinaryFile
+		}
+	}
+	return textFile{Reader: r, Closer: f}, nil
+}
+
+func readText(path, enc string, skipBinary bool) ([]byte, error) {
+	f, err := openText(path, enc, skipBinary)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+	return io.ReadAll(f)
+}
@@ -0,0 +1,84 @@
+package search
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRunDecodesLegacyEncodings(t *testing.T) {
+	root := t.TempDir()
+	inbox := filepath.Join(root, "inbox")
+	if err := os.MkdirAll(inbox, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(inbox, "cp1252.md"), []byte("menu\ncaf\xe9 au lait\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	res, err := Run(context.Background(), root, "café", []string{"inbox"}, 10, Options{Encoding: "windows-1252"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 1 || res[0].Line != 2 || res[0].Preview != "café au lait" {
+		t.Fatalf("unexpected windows-1252 results: %+v", res)
+	}
+	res, err = Run(context.Background(), root, "café", []string{"inbox"}, 10, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 0 {
+		t.Fatalf("expected no match without decoding: %+v", res)
+	}
+
+	if err := os.WriteFile(filepath.Join(inbox, "sjis.md"), []byte("\x93\xfa\x96\x7b\x8c\xea\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	res, err = Run(context.Background(), root, "日本語", []string{"inbox"}, 10, Options{Encoding: "shift_jis", Patterns: []string{"日本"}, Combine: CombineAnd})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 1 || res[0].File != "inbox/sjis.md" || res[0].Preview != "日本語" {
+		t.Fatalf("unexpected shift_jis results: %+v", res)
+	}
+}
+
+func TestRunDecodesUTF16WithoutIncludeBinary(t *testing.T) {
+	root := t.TempDir()
+	inbox := filepath.Join(root, "inbox")
+	if err := os.MkdirAll(inbox, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	var utf16 []byte
+	for _, r := range "intro\nneedle here\n" {
+		utf16 = append(utf16, byte(r), 0)
+	}
+	if err := os.WriteFile(filepath.Join(inbox, "utf16.md"), utf16, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	res, err := Run(context.Background(), root, "needle", []string{"inbox"}, 10, Options{Encoding: "utf-16le"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 1 || res[0].Line != 2 || res[0].Preview != "needle here" {
+		t.Fatalf("unexpected utf-16le results: %+v", res)
+	}
+	res, err = Run(context.Background(), root, "needle", []string{"inbox"}, 10, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 0 {
+		t.Fatalf("undecoded utf-16 should still be skipped as binary: %+v", res)
+	}
+}
+
+func TestValidEncoding(t *testing.T) {
+	for _, name := range []string{"", "utf-8", "windows-1252", "shift_jis", "latin1"} {
+		if !ValidEncoding(name) {
+			t.Fatalf("expected %q to be valid", name)
+		}
+	}
+	if ValidEncoding("klingon") {
+		t.Fatal("expected unknown encoding to be rejected")
+	}
+}
@@ -0,0 +1,42 @@
+package search
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"margin/internal/rootio"
+)
+
+func ResolveFileList(root string, rels []string) ([]string, error) {
+	out := make([]string, 0, len(rels))
+	seen := map[string]bool{}
+	for _, rel := range rels {
+		p := rel
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(root, filepath.FromSlash(rel))
+		}
+		if _, err := rootio.RelUnderRoot(root, p); err != nil {
+			return nil, fmt.Errorf("%s: %w", rel, err)
+		}
+		st, err := os.Stat(p)
+		if err != nil {
+			return nil, err
+		}
+		if st.IsDir() {
+			return nil, fmt.Errorf("%s: is a directory", rel)
+		}
+		if !seen[p] {
+			seen[p] = true
+			out = append(out, p)
+		}
+	}
+	return out, nil
+}
+
+func listFiles(paths []string, opts Options) ([]string, error) {
+	if opts.Files != nil {
+		return opts.Files, nil
+	}
+	return rootio.ListFilesRecursive(paths, rootio.WalkOptions{Hidden: opts.Hidden})
+}
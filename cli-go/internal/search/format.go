@@ -0,0 +1,124 @@
+package search
+
+import (
+	"fmt"
+	"io"
+)
+
+const (
+	FormatJSON = "json"
+	FormatGrep = "grep"
+)
+
+const (
+	EnvelopeArray  = "array"
+	EnvelopeObject = "object"
+)
+
+const EnvelopeSchemaVersion = 1
+
+const GroupByFile = "file"
+
+type FileGroup struct {
+	File    string  `json:"file"`
+	Mtime   string  `json:"mtime"`
+	Matches []Match `json:"matches"`
+}
+
+type Match struct {
+	Line    int      `json:"line"`
+	EndLine int      `json:"end_line,omitempty"`
+	Col     int      `json:"col"`
+	Preview string   `json:"preview"`
+	Anchor  string   `json:"anchor,omitempty"`
+	Before  []string `json:"before,omitempty"`
+	After   []string `json:"after,omitempty"`
+}
+
+type Envelope struct {
+	Results       []Result     `json:"results"`
+	Meta          EnvelopeMeta `json:"meta"`
+	Stats         *Stats       `json:"stats,omitempty"`
+	SchemaVersion int          `json:"schema_version"`
+}
+
+type EnvelopeMeta struct {
+	Queries   []string `json:"queries"`
+	Count     int      `json:"count"`
+	Limit     int      `json:"limit"`
+	Truncated bool     `json:"truncated"`
+}
+
+func ValidFormat(format string) bool {
+	return format == FormatJSON || format == FormatGrep
+}
+
+func ValidEnvelope(envelope string) bool {
+	return envelope == EnvelopeArray || envelope == EnvelopeObject
+}
+
+func ValidGroupBy(groupBy string) bool {
+	return groupBy == "" || groupBy == GroupByFile
+}
+
+func GroupResultsByFile(results []Result) []FileGroup {
+	groups := make([]FileGroup, 0)
+	index := map[string]int{}
+	for _, r := range results {
+		i, ok := index[r.File]
+		if !ok {
+			i = len(groups)
+			index[r.File] = i
+			groups = append(groups, FileGroup{File: r.File, Mtime: r.Mtime})
+		}
+		groups[i].Matches = append(groups[i].Matches, Match{
+			Line:    r.Line,
+			EndLine: r.EndLine,
+			Col:     r.Col,
+			Preview: r.Preview,
+			Anchor:  r.Anchor,
+			Before:  r.Before,
+			After:   r.After,
+		})
+	}
+	return groups
+}
+
+func NewEnvelope(queries []string, results []Result, limit int) Envelope {
+	if results == nil {
+		results = []Result{}
+	}
+	return Envelope{
+		Results: results,
+		Meta: EnvelopeMeta{
+			Queries:   queries,
+			Count:     len(results),
+			Limit:     limit,
+			Truncated: limit > 0 && len(results) >= limit,
+		},
+		SchemaVersion: EnvelopeSchemaVersion,
+	}
+}
+
+func WritePaths(w io.Writer, results []Result) error {
+	seen := map[string]bool{}
+	for _, r := range results {
+		if seen[r.File] {
+			continue
+		}
+		seen[r.File] = true
+		if _, err := fmt.Fprintln(w, r.File); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func WriteGrep(w io.Writer, results []Result) error {
+	for _, r := range results {
+		if _, err := fmt.Fprintf(w, "%s:%d:%d: %s\n", r.File, r.Line, r.Col, r.Preview); err != nil {
+			return err
+		}
+	}
+	return nil
+}
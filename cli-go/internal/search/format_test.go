@@ -0,0 +1,76 @@
+package search
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestWriteGrepFormatsQuickfixLines(t *testing.T) {
+	var sb strings.Builder
+	err := WriteGrep(&sb, []Result{
+		{File: "inbox/a.md", Line: 3, Col: 7, Preview: "hello world"},
+		{File: "scratch/current/b.md", Line: 10, Col: 1, Preview: "world"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "inbox/a.md:3:7: hello world\nscratch/current/b.md:10:1: world\n"
+	if sb.String() != want {
+		t.Fatalf("got %q want %q", sb.String(), want)
+	}
+}
+
+func TestWritePathsDedupes(t *testing.T) {
+	var sb strings.Builder
+	err := WritePaths(&sb, []Result{{File: "inbox/a.md", Line: 1}, {File: "scratch/b.md", Line: 2}, {File: "inbox/a.md", Line: 9}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sb.String() != "inbox/a.md\nscratch/b.md\n" {
+		t.Fatalf("got %q", sb.String())
+	}
+}
+
+func TestNewEnvelopeReportsTruncation(t *testing.T) {
+	env := NewEnvelope([]string{"foo"}, []Result{{File: "a.md"}, {File: "b.md"}}, 2)
+	if env.Meta.Count != 2 || !env.Meta.Truncated || len(env.Meta.Queries) != 1 {
+		t.Fatalf("unexpected meta: %+v", env.Meta)
+	}
+	empty := NewEnvelope([]string{"foo"}, nil, 50)
+	if empty.Meta.Truncated {
+		t.Fatalf("unexpected truncation: %+v", empty.Meta)
+	}
+	b, err := json.Marshal(empty)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(b), `{"results":[],"meta":`) || strings.Contains(string(b), "stats") {
+		t.Fatalf("unexpected json: %s", b)
+	}
+	if !strings.HasSuffix(string(b), `"schema_version":1}`) {
+		t.Fatalf("missing schema_version: %s", b)
+	}
+}
+
+func TestGroupResultsByFilePreservesOrder(t *testing.T) {
+	results := []Result{
+		{File: "b.md", Line: 3, Col: 1, Preview: "b3", Mtime: "t1"},
+		{File: "a.md", Line: 9, Col: 2, Preview: "a9", Mtime: "t2"},
+		{File: "b.md", Line: 1, Col: 4, Preview: "b1", Mtime: "t1"},
+	}
+	groups := GroupResultsByFile(results)
+	if len(groups) != 2 || groups[0].File != "b.md" || groups[1].File != "a.md" || groups[1].Mtime != "t2" {
+		t.Fatalf("unexpected groups: %+v", groups)
+	}
+	if len(groups[0].Matches) != 2 || groups[0].Matches[0].Line != 3 || groups[0].Matches[1].Preview != "b1" {
+		t.Fatalf("matches should keep result order: %+v", groups[0].Matches)
+	}
+	b, err := json.Marshal(GroupResultsByFile(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "[]" {
+		t.Fatalf("empty grouping should encode as [], got %s", b)
+	}
+}
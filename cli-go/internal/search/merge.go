@@ -0,0 +1,42 @@
+package search
+
+import "sort"
+
+const mergedPreviewSep = " " + previewEllipsis + " "
+
+func mergeAdjacent(results []Result, gap int) []Result {
+	if len(results) < 2 {
+		return results
+	}
+	// Keep files in the order they first appear so bleve's relevance ranking survives;
+	// only the matches inside each file are put in line order for merging.
+	order := make([]string, 0)
+	byFile := map[string][]Result{}
+	for _, r := range results {
+		if _, ok := byFile[r.File]; !ok {
+			order = append(order, r.File)
+		}
+		byFile[r.File] = append(byFile[r.File], r)
+	}
+	out := make([]Result, 0, len(results))
+	for _, file := range order {
+		group := byFile[file]
+		sort.SliceStable(group, func(i, j int) bool { return group[i].Line < group[j].Line })
+		start := len(out)
+		for _, r := range group {
+			if n := len(out); n > start {
+				last := &out[n-1]
+				end := max(last.Line, last.EndLine)
+				if r.Line-end <= gap {
+					if r.Line > end {
+						last.Preview += mergedPreviewSep + r.Preview
+						last.EndLine = r.Line
+					}
+					continue
+				}
+			}
+			out = append(out, r)
+		}
+	}
+	return out
+}
@@ -0,0 +1,86 @@
+package search
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"unicode/utf8"
+)
+
+const anchorLength = 12
+
+const previewEllipsis = "…"
+
+const (
+	PreviewTrim      = "trim"
+	PreviewLeftStrip = "left-strip"
+	PreviewNone      = "none"
+)
+
+func ValidPreviewTrim(policy string) bool {
+	switch policy {
+	case "", PreviewTrim, PreviewLeftStrip, PreviewNone:
+		return true
+	default:
+		return false
+	}
+}
+
+func trimPreview(s, policy string) string {
+	switch policy {
+	case PreviewLeftStrip:
+		return strings.TrimLeft(s, " \t\r\n")
+	case PreviewNone:
+		return strings.TrimRight(s, "\r\n")
+	default:
+		return strings.TrimSpace(s)
+	}
+}
+
+func TruncatePreview(s string, limit int) string {
+	if limit <= 0 || len(s) <= limit {
+		return s
+	}
+	end := limit
+	for end > 0 && !utf8.RuneStart(s[end]) {
+		end--
+	}
+	if cut := strings.LastIndexAny(s[:end], " \t"); cut > end/2 {
+		end = cut
+	}
+	return strings.TrimRight(s[:end], " \t")
+}
+
+func LineAnchor(line string) string {
+	sum := sha256.Sum256([]byte(strings.TrimSpace(line)))
+	return hex.EncodeToString(sum[:])[:anchorLength]
+}
+
+func lineAnchor(line string, opts Options) string {
+	if !opts.Anchors {
+		return ""
+	}
+	return LineAnchor(line)
+}
+
+func makePreview(text string, idx, matchLen int, opts Options) string {
+	if opts.PreviewWindow <= 0 || idx < 0 {
+		return trimPreview(text, opts.PreviewTrim)
+	}
+	start := max(0, idx-opts.PreviewWindow)
+	end := min(len(text), idx+matchLen+opts.PreviewWindow)
+	for start > 0 && !utf8.RuneStart(text[start]) {
+		start--
+	}
+	for end < len(text) && !utf8.RuneStart(text[end]) {
+		end++
+	}
+	preview := trimPreview(text[start:end], opts.PreviewTrim)
+	if start > 0 {
+		preview = previewEllipsis + preview
+	}
+	if end < len(text) {
+		preview += previewEllipsis
+	}
+	return preview
+}
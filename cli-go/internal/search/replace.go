@@ -0,0 +1,161 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"margin/internal/rootio"
+)
+
+const diffContextLines = 3
+
+type FileEdit struct {
+	File     string `json:"file"`
+	Count    int    `json:"replacements"`
+	oldLines []string
+	newLines []string
+	noEOL    bool
+}
+
+func PlanReplace(ctx context.Context, root, query, replacement string, groups []string, opts Options) ([]FileEdit, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(query) == "" {
+		return nil, errors.New("query is required")
+	}
+	re, err := regexp.Compile("(?i)" + regexp.QuoteMeta(query))
+	if err != nil {
+		return nil, err
+	}
+	paths := rootio.ResolvePathGroups(root, groups)
+	if opts.ExcludeHistory {
+		paths = rootio.WithoutHistory(paths)
+	}
+	files, err := listFiles(paths, opts)
+	if err != nil {
+		return nil, err
+	}
+	edits := make([]FileEdit, 0)
+	for _, f := range files {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if skipFile(f, opts) {
+			continue
+		}
+		data, err := os.ReadFile(f)
+		if err != nil {
+			continue
+		}
+		if !opts.IncludeBinary && rootio.IsBinaryData(data) {
+			continue
+		}
+		allowed := scopeLines(data, opts.Scope)
+		lines, noEOL := splitLines(string(data))
+		newLines := make([]string, len(lines))
+		count := 0
+		for i, line := range lines {
+			n := 0
+			if lineAllowed(allowed, i+1) {
+				n = len(re.FindAllStringIndex(line, -1))
+			}
+			if n == 0 {
+				newLines[i] = line
+				continue
+			}
+			count += n
+			newLines[i] = re.ReplaceAllLiteralString(line, replacement)
+		}
+		if count == 0 {
+			continue
+		}
+		rel, err := rootio.RelUnderRoot(root, f)
+		if err != nil {
+			rel = filepath.ToSlash(f)
+		}
+		edits = append(edits, FileEdit{
+			File:     rel,
+			Count:    count,
+			oldLines: lines,
+			newLines: newLines,
+			noEOL:    noEOL,
+		})
+	}
+	return edits, nil
+}
+
+func (e FileEdit) UnifiedDiff() string {
+	changed := make([]bool, len(e.oldLines))
+	for i := range e.oldLines {
+		changed[i] = e.oldLines[i] != e.newLines[i]
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- a/%s\n+++ b/%s\n", e.File, e.File)
+	delta := 0
+	i := 0
+	for i < len(changed) {
+		if !changed[i] {
+			i++
+			continue
+		}
+		start := max(0, i-diffContextLines)
+		end := i
+		for j := i; j < len(changed); j++ {
+			if !changed[j] {
+				continue
+			}
+			if j-end-1 > 2*diffContextLines {
+				break
+			}
+			end = j
+		}
+		stop := min(len(changed), end+diffContextLines+1)
+		var body strings.Builder
+		oldCount, newCount := 0, 0
+		for k := start; k < stop; k++ {
+			last := k == len(changed)-1 && e.noEOL
+			if !changed[k] {
+				writeDiffLine(&body, ' ', e.oldLines[k], last)
+				oldCount++
+				newCount++
+				continue
+			}
+			writeDiffLine(&body, '-', e.oldLines[k], last)
+			oldCount++
+			repl := strings.Split(e.newLines[k], "\n")
+			for r, line := range repl {
+				writeDiffLine(&body, '+', line, last && r == len(repl)-1)
+				newCount++
+			}
+		}
+		fmt.Fprintf(&sb, "@@ -%d,%d +%d,%d @@\n", start+1, oldCount, start+1+delta, newCount)
+		sb.WriteString(body.String())
+		delta += newCount - oldCount
+		i = stop
+	}
+	return sb.String()
+}
+
+func writeDiffLine(sb *strings.Builder, prefix byte, line string, noEOL bool) {
+	sb.WriteByte(prefix)
+	sb.WriteString(line)
+	sb.WriteByte('\n')
+	if noEOL {
+		sb.WriteString("\\ No newline at end of file\n")
+	}
+}
+
+func splitLines(s string) ([]string, bool) {
+	if s == "" {
+		return []string{}, false
+	}
+	noEOL := !strings.HasSuffix(s, "\n")
+	s = strings.TrimSuffix(s, "\n")
+	return strings.Split(s, "\n"), noEOL
+}
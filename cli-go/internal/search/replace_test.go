@@ -0,0 +1,94 @@
+package search
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPlanReplaceUnifiedDiff(t *testing.T) {
+	root := t.TempDir()
+	inbox := filepath.Join(root, "inbox")
+	if err := os.MkdirAll(inbox, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(inbox, "note.md"), []byte("a\nold thing\nb\nc"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	edits, err := PlanReplace(context.Background(), root, "old", "new", []string{"inbox"}, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(edits) != 1 || edits[0].Count != 1 {
+		t.Fatalf("unexpected edits: %+v", edits)
+	}
+	want := "--- a/inbox/note.md\n+++ b/inbox/note.md\n@@ -1,4 +1,4 @@\n a\n-old thing\n+new thing\n b\n c\n\\ No newline at end of file\n"
+	if got := edits[0].UnifiedDiff(); got != want {
+		t.Fatalf("unexpected diff:\n%s", got)
+	}
+}
+
+func TestPlanReplaceRestrictedToFileList(t *testing.T) {
+	root := t.TempDir()
+	inbox := filepath.Join(root, "inbox")
+	if err := os.MkdirAll(inbox, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"keep.md", "skip.md"} {
+		if err := os.WriteFile(filepath.Join(inbox, name), []byte("old value\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files, err := ResolveFileList(root, []string{"inbox/keep.md", "inbox/keep.md"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	edits, err := PlanReplace(context.Background(), root, "old", "new", []string{"inbox"}, Options{Files: files})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(edits) != 1 || edits[0].File != "inbox/keep.md" {
+		t.Fatalf("unexpected edits: %+v", edits)
+	}
+	res, err := Run(context.Background(), root, "old", nil, 0, Options{Files: files})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 1 || res[0].File != "inbox/keep.md" {
+		t.Fatalf("unexpected results: %+v", res)
+	}
+	for _, bad := range []string{"../outside.md", "inbox/missing.md", "inbox"} {
+		if _, err := ResolveFileList(root, []string{bad}); err == nil {
+			t.Fatalf("expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestPlanReplaceHonoursScopeAndModifiedBounds(t *testing.T) {
+	root := t.TempDir()
+	inbox := filepath.Join(root, "inbox")
+	if err := os.MkdirAll(inbox, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	note := "# old heading\nold prose\n"
+	if err := os.WriteFile(filepath.Join(inbox, "note.md"), []byte(note), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	edits, err := PlanReplace(context.Background(), root, "old", "new", []string{"inbox"}, Options{Scope: ScopeHeadings})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "--- a/inbox/note.md\n+++ b/inbox/note.md\n@@ -1,2 +1,2 @@\n-# old heading\n+# new heading\n old prose\n"
+	if len(edits) != 1 || edits[0].Count != 1 || edits[0].UnifiedDiff() != want {
+		t.Fatalf("replacement escaped --scope: %+v", edits)
+	}
+	edits, err = PlanReplace(context.Background(), root, "old", "new", []string{"inbox"}, Options{ModifiedAfter: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(edits) != 0 {
+		t.Fatalf("replacement ignored --modified-after: %+v", edits)
+	}
+}
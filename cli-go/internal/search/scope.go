@@ -0,0 +1,122 @@
+package search
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+const (
+	ScopeAll      = "all"
+	ScopeHeadings = "headings"
+	ScopeCode     = "code"
+	ScopeProse    = "prose"
+)
+
+const (
+	lineProse = iota
+	lineHeading
+	lineCode
+	lineFence
+)
+
+func ValidScope(scope string) bool {
+	switch scope {
+	case "", ScopeAll, ScopeHeadings, ScopeCode, ScopeProse:
+		return true
+	default:
+		return false
+	}
+}
+
+func scopeFilter(path string, opts Options) []bool {
+	if opts.Scope == "" || opts.Scope == ScopeAll {
+		return nil
+	}
+	data, err := readText(path, opts.Encoding, false)
+	if err != nil {
+		return []bool{}
+	}
+	return scopeLines(data, opts.Scope)
+}
+
+func scopeLines(data []byte, scope string) []bool {
+	if scope == "" || scope == ScopeAll {
+		return nil
+	}
+	want := map[string]int{ScopeHeadings: lineHeading, ScopeCode: lineCode, ScopeProse: lineProse}[scope]
+	kinds := classifyLines(data)
+	allowed := make([]bool, len(kinds))
+	for i, k := range kinds {
+		allowed[i] = k == want
+	}
+	return allowed
+}
+
+func lineAllowed(allowed []bool, ln int) bool {
+	return allowed == nil || (ln >= 1 && ln <= len(allowed) && allowed[ln-1])
+}
+
+func classifyLines(src []byte) []int {
+	starts := []int{0}
+	for i, b := range src {
+		if b == '\n' && i+1 < len(src) {
+			starts = append(starts, i+1)
+		}
+	}
+	kinds := make([]int, len(starts))
+	lineOf := func(offset int) int {
+		return sort.Search(len(starts), func(i int) bool { return starts[i] > offset }) - 1
+	}
+	mark := func(lines *text.Segments, kind int) (int, int) {
+		first, last := -1, -1
+		for i := 0; i < lines.Len(); i++ {
+			seg := lines.At(i)
+			for ln := lineOf(seg.Start); ln <= lineOf(max(seg.Start, seg.Stop-1)); ln++ {
+				kinds[ln] = kind
+				if first < 0 {
+					first = ln
+				}
+				last = ln
+			}
+		}
+		return first, last
+	}
+	isFence := func(ln int) bool {
+		if ln < 0 || ln >= len(starts) {
+			return false
+		}
+		line := bytes.TrimLeft(src[starts[ln]:], " \t")
+		return bytes.HasPrefix(line, []byte("```")) || bytes.HasPrefix(line, []byte("~~~"))
+	}
+
+	doc := goldmark.New().Parser().Parse(text.NewReader(src))
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch node := n.(type) {
+		case *ast.Heading:
+			mark(node.Lines(), lineHeading)
+			return ast.WalkSkipChildren, nil
+		case *ast.CodeBlock:
+			mark(node.Lines(), lineCode)
+		case *ast.FencedCodeBlock:
+			first, last := mark(node.Lines(), lineCode)
+			if first < 0 {
+				return ast.WalkContinue, nil
+			}
+			if isFence(first - 1) {
+				kinds[first-1] = lineFence
+			}
+			if isFence(last + 1) {
+				kinds[last+1] = lineFence
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+	return kinds
+}
@@ -0,0 +1,37 @@
+package search
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestScopeRestrictsMatchesToNodeType(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "inbox")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	note := "# widget plans\n\nThe widget is late.\n\n```go\nwidget := 1\n```\n"
+	if err := os.WriteFile(filepath.Join(dir, "a.md"), []byte(note), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cases := map[string]int{ScopeHeadings: 1, ScopeProse: 3, ScopeCode: 6}
+	for scope, line := range cases {
+		res, err := Run(context.Background(), root, "widget", []string{"inbox"}, 10, Options{Scope: scope})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(res) != 1 || res[0].Line != line {
+			t.Fatalf("scope %s: got %+v, want one match on line %d", scope, res, line)
+		}
+	}
+	res, err := Run(context.Background(), root, "go", []string{"inbox"}, 10, Options{Scope: ScopeCode})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 0 {
+		t.Fatalf("fence info string should not match code scope: %+v", res)
+	}
+}
@@ -3,14 +3,17 @@ package search
 import (
 	"bufio"
 	"context"
+	"fmt"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/blevesearch/bleve/v2"
 
+	"margin/internal/config"
 	"margin/internal/rootio"
 )
 
@@ -19,58 +22,244 @@ const (
 	defaultResultSize = 64
 )
 
+type Options struct {
+	Hidden           bool
+	ExcludeHistory   bool
+	Files            []string
+	Anchors          bool
+	IncludeBinary    bool
+	PreviewWindow    int
+	PreviewTrim      string
+	ContextLines     int
+	PreviewLines     int
+	MergeAdjacent    int
+	ModifiedAfter    time.Time
+	ModifiedBefore   time.Time
+	PathStyle        string
+	Scope            string
+	Invert           bool
+	AcrossLines      bool
+	FilesWithMatches bool
+	MinQueryLength   int
+	MaxResults       int
+	Patterns         []string
+	Combine          string
+	Encoding         string
+}
+
+const (
+	CombineAnd = "and"
+	CombineOr  = "or"
+)
+
+const (
+	PathsRelativeToRoot = "root"
+	PathsRelativeToCWD  = "cwd"
+	PathsAbsolute       = "abs"
+)
+
+func ValidPathStyle(style string) bool {
+	switch style {
+	case "", PathsRelativeToRoot, PathsRelativeToCWD, PathsAbsolute:
+		return true
+	default:
+		return false
+	}
+}
+
 type Result struct {
-	File    string `json:"file"`
-	Line    int    `json:"line"`
-	Col     int    `json:"col"`
-	Preview string `json:"preview"`
-	Mtime   string `json:"mtime"`
+	File    string   `json:"file"`
+	Line    int      `json:"line"`
+	EndLine int      `json:"end_line,omitempty"`
+	Col     int      `json:"col"`
+	Preview string   `json:"preview"`
+	Anchor  string   `json:"anchor,omitempty"`
+	Mtime   string   `json:"mtime"`
+	Before  []string `json:"before,omitempty"`
+	After   []string `json:"after,omitempty"`
 }
 
-func Run(ctx context.Context, root, query string, groups []string, limit int) ([]Result, error) {
+const (
+	BackendBleve    = "bleve"
+	BackendFallback = "fallback"
+)
+
+type Stats struct {
+	ElapsedMS    int64  `json:"elapsed_ms"`
+	Backend      string `json:"backend"`
+	FilesScanned int    `json:"files_scanned"`
+	Matches      int    `json:"matches"`
+}
+
+func Run(ctx context.Context, root, query string, groups []string, limit int, opts Options) ([]Result, error) {
+	res, _, err := RunWithStats(ctx, root, query, groups, limit, opts)
+	return res, err
+}
+
+func EffectiveLimit(limit, maxResults int) int {
+	if maxResults > 0 && (limit <= 0 || limit > maxResults) {
+		return maxResults
+	}
+	return max(0, limit)
+}
+
+func RunWithStats(ctx context.Context, root, query string, groups []string, limit int, opts Options) ([]Result, Stats, error) {
+	started := time.Now()
+	limit = EffectiveLimit(limit, opts.MaxResults)
+	stats := Stats{}
+	finish := func(res []Result) Stats {
+		stats.ElapsedMS = time.Since(started).Milliseconds()
+		stats.Matches = len(res)
+		return stats
+	}
 	if err := ctx.Err(); err != nil {
-		return nil, err
+		return nil, stats, err
 	}
-	if strings.TrimSpace(query) == "" {
-		return []Result{}, nil
+	if trimmed := strings.TrimSpace(query); trimmed == "" || utf8.RuneCountInString(trimmed) < opts.MinQueryLength {
+		return []Result{}, finish(nil), nil
 	}
 	paths := rootio.ResolvePathGroups(root, groups)
-	if len(paths) == 0 {
-		return []Result{}, nil
+	if opts.ExcludeHistory {
+		paths = rootio.WithoutHistory(paths)
+	}
+	if len(paths) == 0 && opts.Files == nil {
+		return []Result{}, finish(nil), nil
+	}
+	var res []Result
+	var scanned int
+	var err error
+	switch {
+	case opts.AcrossLines:
+		stats.Backend = BackendFallback
+		res, scanned, err = runAcrossLines(ctx, root, query, paths, limit, opts)
+	case opts.Invert || opts.FilesWithMatches || len(opts.Patterns) > 0:
+		// bleve can only rank single-query hits, so these modes go to the line scanner.
+		stats.Backend = BackendFallback
+		res, scanned, err = runFallback(ctx, root, query, paths, limit, opts)
+	default:
+		stats.Backend = BackendBleve
+		if res, scanned, err = runBleve(ctx, root, query, paths, limit, opts); err != nil {
+			stats.Backend = BackendFallback
+			res, scanned, err = runFallback(ctx, root, query, paths, limit, opts)
+		}
+	}
+	if err != nil {
+		return nil, stats, err
+	}
+	stats.FilesScanned = scanned
+	if opts.PreviewLines > 1 {
+		extendPreviews(root, res, opts)
+	}
+	if opts.MergeAdjacent > 0 {
+		res = mergeAdjacent(res, opts.MergeAdjacent)
+	}
+	if opts.ContextLines > 0 {
+		attachContext(root, res, opts.ContextLines, opts.Encoding)
 	}
-	res, err := runBleve(ctx, root, query, paths, limit)
-	if err == nil {
-		return res, nil
+	if opts.PathStyle == PathsRelativeToCWD || opts.PathStyle == PathsAbsolute {
+		restylePaths(root, res, opts.PathStyle)
+	}
+	return res, finish(res), nil
+}
+
+func restylePaths(root string, results []Result, style string) {
+	cwd, _ := os.Getwd()
+	for i := range results {
+		p := filepath.FromSlash(results[i].File)
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(root, p)
+		}
+		abs, err := filepath.Abs(p)
+		if err != nil {
+			continue
+		}
+		results[i].File = abs
+		if style != PathsRelativeToCWD || cwd == "" {
+			continue
+		}
+		if rel, err := filepath.Rel(cwd, abs); err == nil {
+			results[i].File = rel
+		}
+	}
+}
+
+type lineCache map[string][]string
+
+func (c lineCache) lines(root, file, enc string) []string {
+	lines, ok := c[file]
+	if !ok {
+		p := filepath.FromSlash(file)
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(root, p)
+		}
+		if data, err := readText(p, enc, false); err == nil {
+			lines = strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+		}
+		c[file] = lines
+	}
+	return lines
+}
+
+func extendPreviews(root string, results []Result, opts Options) {
+	cache := lineCache{}
+	for i := range results {
+		r := &results[i]
+		lines := cache.lines(root, r.File, opts.Encoding)
+		if r.Line < 1 || r.Line > len(lines) {
+			continue
+		}
+		end := min(len(lines), r.Line-1+opts.PreviewLines)
+		if end == len(lines) && end > r.Line && lines[end-1] == "" {
+			end--
+		}
+		parts := []string{r.Preview}
+		for _, line := range lines[r.Line:end] {
+			parts = append(parts, trimPreview(line, opts.PreviewTrim))
+		}
+		r.Preview = strings.Join(parts, "\n")
+	}
+}
+
+func attachContext(root string, results []Result, n int, enc string) {
+	cache := lineCache{}
+	for i := range results {
+		r := &results[i]
+		lines := cache.lines(root, r.File, enc)
+		if r.Line < 1 || r.Line > len(lines) {
+			continue
+		}
+		r.Before = append([]string{}, lines[max(0, r.Line-1-n):r.Line-1]...)
+		end := min(len(lines), max(r.Line, r.EndLine))
+		r.After = append([]string{}, lines[end:min(len(lines), end+n)]...)
 	}
-	return runFallback(ctx, root, query, paths, limit)
 }
 
 type bleveLineDoc struct {
 	File    string `json:"file"`
 	Line    int    `json:"line"`
-	Preview string `json:"preview"`
 	Content string `json:"content"`
 	Mtime   string `json:"mtime"`
 }
 
-func runBleve(ctx context.Context, root, query string, paths []string, limit int) ([]Result, error) {
+func runBleve(ctx context.Context, root, query string, paths []string, limit int, opts Options) ([]Result, int, error) {
 	if err := ctx.Err(); err != nil {
-		return nil, err
+		return nil, 0, err
 	}
-	files, err := rootio.ListFilesRecursive(paths)
+	files, err := listFiles(paths, opts)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 	index, err := bleve.NewMemOnly(bleve.NewIndexMapping())
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 	defer func() {
 		_ = index.Close()
 	}()
+	scanned := 0
 	for _, f := range files {
 		if err := ctx.Err(); err != nil {
-			return nil, err
+			return nil, 0, err
 		}
 		rel, err := rootio.RelUnderRoot(root, f)
 		if err != nil {
@@ -80,30 +269,37 @@ func runBleve(ctx context.Context, root, query string, paths []string, limit int
 		if st, err := os.Stat(f); err == nil {
 			mtime = st.ModTime().Format(time.RFC3339)
 		}
-		fh, err := os.Open(f)
+		if skipFile(f, opts) {
+			continue
+		}
+		fh, err := openText(f, opts.Encoding, !opts.IncludeBinary)
 		if err != nil {
 			continue
 		}
+		allowed := scopeFilter(f, opts)
+		scanned++
 		s := bufio.NewScanner(fh)
 		s.Buffer(make([]byte, 64*1024), maxScannerToken)
 		ln := 0
 		for s.Scan() {
 			if err := ctx.Err(); err != nil {
 				_ = fh.Close()
-				return nil, err
+				return nil, 0, err
 			}
 			ln++
+			if !lineAllowed(allowed, ln) {
+				continue
+			}
 			lineText := s.Text()
 			doc := bleveLineDoc{
 				File:    rel,
 				Line:    ln,
-				Preview: strings.TrimSpace(lineText),
 				Content: lineText,
 				Mtime:   mtime,
 			}
 			if err := index.Index(rel+":"+strconv.Itoa(ln), doc); err != nil {
 				_ = fh.Close()
-				return nil, err
+				return nil, 0, err
 			}
 		}
 		_ = fh.Close()
@@ -113,23 +309,27 @@ func runBleve(ctx context.Context, root, query string, paths []string, limit int
 	q.SetField("content")
 	size := limit
 	if size <= 0 {
-		size = 50
+		count, err := index.DocCount()
+		if err != nil {
+			return nil, 0, err
+		}
+		size = int(count)
 	}
 	req := bleve.NewSearchRequestOptions(q, size, 0, false)
-	req.Fields = []string{"file", "line", "preview", "mtime", "content"}
+	req.Fields = []string{"file", "line", "mtime", "content"}
 	res, err := index.SearchInContext(ctx, req)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 	out := make([]Result, 0, len(res.Hits))
 	for _, hit := range res.Hits {
 		fields := hit.Fields
 		file, _ := fields["file"].(string)
-		preview, _ := fields["preview"].(string)
 		mtime, _ := fields["mtime"].(string)
 		content, _ := fields["content"].(string)
 		line := int(numberField(fields["line"]))
-		col := strings.Index(strings.ToLower(content), strings.ToLower(query)) + 1
+		idx := strings.Index(strings.ToLower(content), strings.ToLower(query))
+		col := idx + 1
 		if col <= 0 {
 			col = 1
 		}
@@ -137,11 +337,40 @@ func runBleve(ctx context.Context, root, query string, paths []string, limit int
 			File:    file,
 			Line:    line,
 			Col:     col,
-			Preview: preview,
+			Preview: makePreview(content, idx, len(query), opts),
+			Anchor:  lineAnchor(content, opts),
 			Mtime:   mtime,
 		})
 	}
-	return out, nil
+	return out, scanned, nil
+}
+
+func skipFile(path string, opts Options) bool {
+	if opts.ModifiedAfter.IsZero() && opts.ModifiedBefore.IsZero() {
+		return false
+	}
+	st, err := os.Stat(path)
+	if err != nil {
+		return true
+	}
+	if !opts.ModifiedAfter.IsZero() && st.ModTime().Before(opts.ModifiedAfter) {
+		return true
+	}
+	return !opts.ModifiedBefore.IsZero() && st.ModTime().After(opts.ModifiedBefore)
+}
+
+func ParseTimeBound(raw string, now time.Time) (time.Time, error) {
+	raw = strings.TrimSpace(raw)
+	if d, err := config.ParseRelativeDuration(raw); err == nil {
+		return now.Add(-d), nil
+	}
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", raw, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q: expected RFC3339, YYYY-MM-DD, or relative like 7d", raw)
 }
 
 func numberField(v any) float64 {
@@ -159,36 +388,141 @@ func numberField(v any) float64 {
 	}
 }
 
-func runFallback(ctx context.Context, root, query string, paths []string, limit int) ([]Result, error) {
+func runAcrossLines(ctx context.Context, root, query string, paths []string, limit int, opts Options) ([]Result, int, error) {
+	terms := strings.Fields(strings.ToLower(query))
+	if len(opts.Patterns) > 0 {
+		terms = lowerPatterns(query, opts.Patterns)
+	}
+	files, err := listFiles(paths, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	results := make([]Result, 0, defaultResultSize)
+	scanned := 0
+	for _, f := range files {
+		if err := ctx.Err(); err != nil {
+			return nil, 0, err
+		}
+		if skipFile(f, opts) {
+			continue
+		}
+		data, err := readText(f, opts.Encoding, !opts.IncludeBinary)
+		if err != nil {
+			continue
+		}
+		scanned++
+		allowed := scopeFilter(f, opts)
+		lines := strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+		found := make([]bool, len(terms))
+		remaining := len(terms)
+		first := Result{}
+		for i, text := range lines {
+			if remaining == 0 {
+				break
+			}
+			if !lineAllowed(allowed, i+1) {
+				continue
+			}
+			lower := strings.ToLower(text)
+			for t, term := range terms {
+				if found[t] {
+					continue
+				}
+				idx := strings.Index(lower, term)
+				if idx < 0 {
+					continue
+				}
+				found[t] = true
+				remaining--
+				if t == 0 {
+					first = Result{Line: i + 1, Col: idx + 1, Preview: makePreview(text, idx, len(term), opts), Anchor: lineAnchor(text, opts)}
+				}
+			}
+		}
+		if remaining > 0 {
+			continue
+		}
+		first.File, err = rootio.RelUnderRoot(root, f)
+		if err != nil {
+			first.File = filepath.ToSlash(f)
+		}
+		if st, err := os.Stat(f); err == nil {
+			first.Mtime = st.ModTime().Format(time.RFC3339)
+		}
+		results = append(results, first)
+		if limit > 0 && len(results) >= limit {
+			break
+		}
+	}
+	return results, scanned, nil
+}
+
+func lowerPatterns(query string, extra []string) []string {
+	out := []string{strings.ToLower(query)}
+	for _, p := range extra {
+		if strings.TrimSpace(p) != "" {
+			out = append(out, strings.ToLower(p))
+		}
+	}
+	return out
+}
+
+func matchLine(lower string, patterns []string, combine string) (int, int) {
+	first, firstLen := -1, 0
+	for _, p := range patterns {
+		idx := strings.Index(lower, p)
+		if idx < 0 {
+			if combine == CombineAnd {
+				return -1, 0
+			}
+			continue
+		}
+		if first < 0 || idx < first {
+			first, firstLen = idx, len(p)
+		}
+	}
+	return first, firstLen
+}
+
+func runFallback(ctx context.Context, root, query string, paths []string, limit int, opts Options) ([]Result, int, error) {
 	if err := ctx.Err(); err != nil {
-		return nil, err
+		return nil, 0, err
 	}
-	files, err := rootio.ListFilesRecursive(paths)
+	files, err := listFiles(paths, opts)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 	results := make([]Result, 0, defaultResultSize)
-	qLower := strings.ToLower(query)
+	patterns := lowerPatterns(query, opts.Patterns)
+	scanned := 0
 	for _, f := range files {
 		if err := ctx.Err(); err != nil {
-			return nil, err
+			return nil, 0, err
+		}
+		if skipFile(f, opts) {
+			continue
 		}
-		file, err := os.Open(f)
+		file, err := openText(f, opts.Encoding, !opts.IncludeBinary)
 		if err != nil {
 			continue
 		}
+		allowed := scopeFilter(f, opts)
+		scanned++
 		s := bufio.NewScanner(file)
 		s.Buffer(make([]byte, 64*1024), maxScannerToken)
 		ln := 0
 		for s.Scan() {
 			if err := ctx.Err(); err != nil {
 				_ = file.Close()
-				return nil, err
+				return nil, 0, err
 			}
 			ln++
+			if !lineAllowed(allowed, ln) {
+				continue
+			}
 			text := s.Text()
-			idx := strings.Index(strings.ToLower(text), qLower)
-			if idx < 0 {
+			idx, matchLen := matchLine(strings.ToLower(text), patterns, opts.Combine)
+			if (idx >= 0) == opts.Invert {
 				continue
 			}
 			rel, err := rootio.RelUnderRoot(root, f)
@@ -202,13 +536,17 @@ func runFallback(ctx context.Context, root, query string, paths []string, limit
 			results = append(results, Result{
 				File:    rel,
 				Line:    ln,
-				Col:     idx + 1,
-				Preview: strings.TrimSpace(text),
+				Col:     max(1, idx+1),
+				Preview: makePreview(text, idx, matchLen, opts),
+				Anchor:  lineAnchor(text, opts),
 				Mtime:   mtime,
 			})
 			if limit > 0 && len(results) >= limit {
 				_ = file.Close()
-				return results, nil
+				return results, scanned, nil
+			}
+			if opts.FilesWithMatches {
+				break
 			}
 		}
 		if err := s.Err(); err != nil {
@@ -217,5 +555,5 @@ func runFallback(ctx context.Context, root, query string, paths []string, limit
 		}
 		_ = file.Close()
 	}
-	return results, nil
+	return results, scanned, nil
 }
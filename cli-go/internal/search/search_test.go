@@ -4,8 +4,11 @@ import (
 	"context"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
+	"unicode/utf8"
 )
 
 func TestRunFallbackHandlesLongLines(t *testing.T) {
@@ -19,7 +22,7 @@ func TestRunFallbackHandlesLongLines(t *testing.T) {
 	if err := os.WriteFile(filepath.Join(dir, "note.md"), []byte(content+"\n"), 0o644); err != nil {
 		t.Fatal(err)
 	}
-	res, err := runFallback(context.Background(), root, "needle", []string{dir}, 10)
+	res, _, err := runFallback(context.Background(), root, "needle", []string{dir}, 10, Options{})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -41,7 +44,7 @@ func TestRunUsesBleveIndex(t *testing.T) {
 	if err := os.WriteFile(note, []byte("alpha beta gamma\n"), 0o644); err != nil {
 		t.Fatal(err)
 	}
-	res, err := Run(context.Background(), root, "beta", []string{"inbox"}, 10)
+	res, err := Run(context.Background(), root, "beta", []string{"inbox"}, 10, Options{})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -55,3 +58,489 @@ func TestRunUsesBleveIndex(t *testing.T) {
 		t.Fatalf("preview=%q", res[0].Preview)
 	}
 }
+
+func TestRunWithStatsReportsBackendAndCounts(t *testing.T) {
+	root := t.TempDir()
+	inbox := filepath.Join(root, "inbox")
+	if err := os.MkdirAll(inbox, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a.md", "b.md"} {
+		if err := os.WriteFile(filepath.Join(inbox, name), []byte("alpha beta\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	res, stats, err := RunWithStats(context.Background(), root, "beta", []string{"inbox"}, 10, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Backend != BackendBleve {
+		t.Fatalf("backend=%s", stats.Backend)
+	}
+	if stats.FilesScanned != 2 || stats.Matches != len(res) {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestRunFallbackSkipsBinaryByDefault(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "inbox")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "blob.pdf"), []byte("needle\x00\x01"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	res, _, err := runFallback(context.Background(), root, "needle", []string{dir}, 10, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 0 {
+		t.Fatalf("expected binary file to be skipped, got %v", res)
+	}
+	res, _, err = runFallback(context.Background(), root, "needle", []string{dir}, 10, Options{IncludeBinary: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 1 {
+		t.Fatalf("expected binary file with --include-binary, got %v", res)
+	}
+}
+
+func TestMakePreviewCentersOnMatch(t *testing.T) {
+	text := strings.Repeat("a", 200) + " needle " + strings.Repeat("b", 200)
+	idx := strings.Index(text, "needle")
+	got := makePreview(text, idx, len("needle"), Options{PreviewWindow: 10})
+	want := "…" + strings.Repeat("a", 9) + " needle " + strings.Repeat("b", 9) + "…"
+	if got != want {
+		t.Fatalf("preview=%q", got)
+	}
+	if got := makePreview("  short needle  ", 8, 6, Options{PreviewWindow: 80}); got != "short needle" {
+		t.Fatalf("preview=%q", got)
+	}
+}
+
+func TestTruncatePreviewKeepsRunesWhole(t *testing.T) {
+	s := strings.Repeat("a", 179) + "é tail"
+	got := TruncatePreview(s, 180)
+	if !utf8.ValidString(got) || got != strings.Repeat("a", 179) {
+		t.Fatalf("preview=%q", got)
+	}
+	if got := TruncatePreview("hello wonderful world", 15); got != "hello wonderful" {
+		t.Fatalf("preview=%q", got)
+	}
+	if got := TruncatePreview("short", 180); got != "short" {
+		t.Fatalf("preview=%q", got)
+	}
+	text := "日本語 needle 日本語"
+	idx := strings.Index(text, "needle")
+	if got := makePreview(text, idx, len("needle"), Options{PreviewWindow: 2}); !utf8.ValidString(got) {
+		t.Fatalf("preview=%q", got)
+	}
+}
+
+func TestMakePreviewTrimPolicies(t *testing.T) {
+	text := "\tif x {  \r"
+	cases := map[string]string{
+		PreviewTrim:      "if x {",
+		PreviewLeftStrip: "if x {  \r",
+		PreviewNone:      "\tif x {  ",
+	}
+	for policy, want := range cases {
+		if got := makePreview(text, 1, 2, Options{PreviewTrim: policy}); got != want {
+			t.Fatalf("%s: preview=%q want %q", policy, got, want)
+		}
+	}
+}
+
+func TestRunAttachesContextLines(t *testing.T) {
+	root := t.TempDir()
+	inbox := filepath.Join(root, "inbox")
+	if err := os.MkdirAll(inbox, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(inbox, "note.md"), []byte("one\ntwo\nneedle\nfour\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	res, err := Run(context.Background(), root, "needle", []string{"inbox"}, 10, Options{ContextLines: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 1 {
+		t.Fatalf("expected 1 result, got %d", len(res))
+	}
+	if len(res[0].Before) != 1 || res[0].Before[0] != "two" || len(res[0].After) != 1 || res[0].After[0] != "four" {
+		t.Fatalf("unexpected context: %+v", res[0])
+	}
+}
+
+func TestRunPreviewLinesReadsAhead(t *testing.T) {
+	root := t.TempDir()
+	inbox := filepath.Join(root, "inbox")
+	if err := os.MkdirAll(inbox, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	note := "intro\n  the needle sits\n  in wrapped prose\nthat continues\nlast\n"
+	if err := os.WriteFile(filepath.Join(inbox, "note.md"), []byte(note), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	res, err := Run(context.Background(), root, "needle", []string{"inbox"}, 10, Options{PreviewLines: 3, ContextLines: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 1 || res[0].Preview != "the needle sits\nin wrapped prose\nthat continues" {
+		t.Fatalf("unexpected preview: %+v", res)
+	}
+	if len(res[0].After) != 1 || res[0].After[0] != "  in wrapped prose" {
+		t.Fatalf("context should be unaffected: %+v", res[0])
+	}
+	res, err = Run(context.Background(), root, "last", []string{"inbox"}, 10, Options{PreviewLines: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 1 || res[0].Preview != "last" {
+		t.Fatalf("preview should stop at end of file: %+v", res)
+	}
+}
+
+func TestRunMergesAdjacentMatches(t *testing.T) {
+	root := t.TempDir()
+	inbox := filepath.Join(root, "inbox")
+	if err := os.MkdirAll(inbox, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	note := "needle a\nfiller\nneedle b\nfiller\nfiller\nfiller\nneedle c\ntail\n"
+	if err := os.WriteFile(filepath.Join(inbox, "note.md"), []byte(note), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	res, err := Run(context.Background(), root, "needle", []string{"inbox"}, 10, Options{MergeAdjacent: 2, ContextLines: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 2 {
+		t.Fatalf("expected 2 merged results, got %+v", res)
+	}
+	first := res[0]
+	if first.Line != 1 || first.EndLine != 3 || first.Preview != "needle a … needle b" {
+		t.Fatalf("unexpected merged result: %+v", first)
+	}
+	if len(first.After) != 1 || first.After[0] != "filler" {
+		t.Fatalf("context should follow the span end: %+v", first)
+	}
+	if res[1].Line != 7 || res[1].EndLine != 0 || res[1].After[0] != "tail" {
+		t.Fatalf("unexpected trailing result: %+v", res[1])
+	}
+}
+
+func TestParseTimeBound(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	got, err := ParseTimeBound("7d", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Equal(now.Add(-7 * 24 * time.Hour)) {
+		t.Fatalf("got %v", got)
+	}
+	if _, err := ParseTimeBound("2026-03-01T00:00:00Z", now); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseTimeBound("last week", now); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestRunFallbackFiltersByMtime(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "inbox")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	oldNote := filepath.Join(dir, "old.md")
+	for _, p := range []string{oldNote, filepath.Join(dir, "new.md")} {
+		if err := os.WriteFile(p, []byte("needle\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	past := time.Now().Add(-30 * 24 * time.Hour)
+	if err := os.Chtimes(oldNote, past, past); err != nil {
+		t.Fatal(err)
+	}
+	res, _, err := runFallback(context.Background(), root, "needle", []string{dir}, 10, Options{ModifiedAfter: time.Now().Add(-24 * time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 1 || res[0].File != "inbox/new.md" {
+		t.Fatalf("unexpected results: %+v", res)
+	}
+}
+
+func TestRestylePaths(t *testing.T) {
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := []Result{{File: "inbox/a.md"}}
+	restylePaths(root, res, PathsAbsolute)
+	want := filepath.Join(root, "inbox", "a.md")
+	if res[0].File != want {
+		t.Fatalf("abs=%q want %q", res[0].File, want)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(root); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.Chdir(wd) }()
+	res = []Result{{File: "inbox/a.md"}}
+	restylePaths(root, res, PathsRelativeToCWD)
+	if res[0].File != filepath.Join("inbox", "a.md") {
+		t.Fatalf("cwd=%q", res[0].File)
+	}
+}
+
+func TestRunInvertReturnsNonMatchingLines(t *testing.T) {
+	root := t.TempDir()
+	inbox := filepath.Join(root, "inbox")
+	if err := os.MkdirAll(inbox, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(inbox, "note.md"), []byte("TODO a due:1d\nTODO b\nplain\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	res, stats, err := RunWithStats(context.Background(), root, "due:", []string{"inbox"}, 10, Options{Invert: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Backend != BackendFallback {
+		t.Fatalf("backend=%s", stats.Backend)
+	}
+	if len(res) != 2 || res[0].Line != 2 || res[1].Line != 3 || res[0].Col != 1 || res[0].Preview != "TODO b" {
+		t.Fatalf("unexpected results: %+v", res)
+	}
+}
+
+func TestRunFilesWithMatchesReturnsFirstHitPerFile(t *testing.T) {
+	root := t.TempDir()
+	inbox := filepath.Join(root, "inbox")
+	if err := os.MkdirAll(inbox, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"a.md": "TODO a due:1d\nTODO b\nplain\n",
+		"b.md": "TODO c due:2d\n",
+		"c.md": "x\nTODO d\n",
+	}
+	for name, body := range files {
+		if err := os.WriteFile(filepath.Join(inbox, name), []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	res, stats, err := RunWithStats(context.Background(), root, "due:", []string{"inbox"}, 10, Options{Invert: true, FilesWithMatches: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Backend != BackendFallback {
+		t.Fatalf("backend=%s", stats.Backend)
+	}
+	if len(res) != 2 || res[0].File != "inbox/a.md" || res[0].Line != 2 || res[1].File != "inbox/c.md" || res[1].Line != 1 {
+		t.Fatalf("unexpected results: %+v", res)
+	}
+	res, err = Run(context.Background(), root, "todo", []string{"inbox"}, 2, Options{FilesWithMatches: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 2 || res[0].File != "inbox/a.md" || res[1].File != "inbox/b.md" {
+		t.Fatalf("expected the limit to count files: %+v", res)
+	}
+}
+
+func TestRunAcrossLinesRequiresAllTermsPerFile(t *testing.T) {
+	root := t.TempDir()
+	inbox := filepath.Join(root, "inbox")
+	if err := os.MkdirAll(inbox, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"both.md":  "intro\nthe budget review\n\nlater we discuss hiring\n",
+		"one.md":   "budget only\n",
+		"split.md": "Hiring first\nthen budget\n",
+	}
+	for name, body := range files {
+		if err := os.WriteFile(filepath.Join(inbox, name), []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	res, err := Run(context.Background(), root, "budget hiring", []string{"inbox"}, 10, Options{AcrossLines: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 2 {
+		t.Fatalf("expected 2 files, got %+v", res)
+	}
+	if res[0].File != "inbox/both.md" || res[0].Line != 2 || res[0].Col != 5 {
+		t.Fatalf("unexpected first result: %+v", res[0])
+	}
+	if res[1].File != "inbox/split.md" || res[1].Line != 2 {
+		t.Fatalf("expected first line of first term: %+v", res[1])
+	}
+}
+
+func TestRunSkipsQueriesShorterThanMinimum(t *testing.T) {
+	root := t.TempDir()
+	inbox := filepath.Join(root, "inbox")
+	if err := os.MkdirAll(inbox, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(inbox, "note.md"), []byte("a.b\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	res, err := Run(context.Background(), root, " a. ", []string{"inbox"}, 10, Options{MinQueryLength: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 0 {
+		t.Fatalf("expected short query to return nothing: %+v", res)
+	}
+	res, err = Run(context.Background(), root, "a.b", []string{"inbox"}, 10, Options{MinQueryLength: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 1 {
+		t.Fatalf("expected query at minimum length to match: %+v", res)
+	}
+}
+
+func TestRunCombinesPatterns(t *testing.T) {
+	root := t.TempDir()
+	inbox := filepath.Join(root, "inbox")
+	if err := os.MkdirAll(inbox, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(inbox, "note.md"), []byte("foo only\nbar only\nfoo and bar\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	and, err := Run(context.Background(), root, "foo", []string{"inbox"}, 10, Options{Patterns: []string{"bar"}, Combine: CombineAnd})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(and) != 1 || and[0].Line != 3 {
+		t.Fatalf("unexpected and results: %+v", and)
+	}
+	or, err := Run(context.Background(), root, "foo", []string{"inbox"}, 10, Options{Patterns: []string{"bar"}, Combine: CombineOr})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(or) != 3 || or[1].Line != 2 || or[1].Col != 1 {
+		t.Fatalf("unexpected or results: %+v", or)
+	}
+	across, err := Run(context.Background(), root, "only", []string{"inbox"}, 10, Options{Patterns: []string{"foo and"}, Combine: CombineAnd, AcrossLines: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(across) != 1 || across[0].Line != 1 {
+		t.Fatalf("unexpected across-lines results: %+v", across)
+	}
+}
+
+func TestLimitZeroAndNegativeAcrossBackends(t *testing.T) {
+	root := t.TempDir()
+	inbox := filepath.Join(root, "inbox")
+	if err := os.MkdirAll(inbox, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	var b strings.Builder
+	for i := 0; i < 60; i++ {
+		b.WriteString("needle other\n")
+	}
+	for i := 0; i < 5; i++ {
+		name := filepath.Join(inbox, "n"+strconv.Itoa(i)+".md")
+		if err := os.WriteFile(name, []byte(b.String()), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	backends := map[string]Options{
+		"bleve":        {},
+		"fallback":     {Patterns: []string{"other"}, Combine: CombineAnd},
+		"across-lines": {AcrossLines: true},
+	}
+	all := map[string]int{"bleve": 300, "fallback": 300, "across-lines": 5}
+	for name, opts := range backends {
+		for _, limit := range []int{0, -1} {
+			res, err := Run(context.Background(), root, "needle", []string{"inbox"}, limit, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(res) != all[name] {
+				t.Fatalf("%s limit=%d: got %d results, want %d", name, limit, len(res), all[name])
+			}
+			capped := opts
+			capped.MaxResults = 3
+			res, err = Run(context.Background(), root, "needle", []string{"inbox"}, limit, capped)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(res) != 3 {
+				t.Fatalf("%s limit=%d max=3: got %d results", name, limit, len(res))
+			}
+		}
+	}
+	if got := EffectiveLimit(50, 10); got != 10 {
+		t.Fatalf("EffectiveLimit(50, 10)=%d", got)
+	}
+	if got := EffectiveLimit(5, 10); got != 5 {
+		t.Fatalf("EffectiveLimit(5, 10)=%d", got)
+	}
+}
+
+func TestAnchorsSurviveLineShifts(t *testing.T) {
+	root := t.TempDir()
+	inbox := filepath.Join(root, "inbox")
+	if err := os.MkdirAll(inbox, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(inbox, "a.md")
+	if err := os.WriteFile(path, []byte("intro\n  the needle line  \n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	want := LineAnchor("the needle line")
+	backends := []Options{{Anchors: true}, {Anchors: true, Patterns: []string{"line"}, Combine: CombineAnd}}
+	for _, opts := range backends {
+		res, err := Run(context.Background(), root, "needle", []string{"inbox"}, 10, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(res) != 1 || res[0].Anchor != want || len(want) != anchorLength {
+			t.Fatalf("unexpected results: %+v", res)
+		}
+	}
+	if err := os.WriteFile(path, []byte("new\nlines\nintro\nthe needle line\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	res, err := Run(context.Background(), root, "needle", []string{"inbox"}, 10, Options{Anchors: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 1 || res[0].Line != 4 || res[0].Anchor != want {
+		t.Fatalf("anchor changed after shift: %+v", res)
+	}
+	if res, _ := Run(context.Background(), root, "needle", []string{"inbox"}, 10, Options{}); res[0].Anchor != "" {
+		t.Fatalf("anchor should be opt-in: %+v", res)
+	}
+}
+
+func TestMergeAdjacentKeepsFileRankOrder(t *testing.T) {
+	res := mergeAdjacent([]Result{
+		{File: "inbox/z.md", Line: 9, Preview: "z9"},
+		{File: "inbox/a.md", Line: 4, Preview: "a4"},
+		{File: "inbox/z.md", Line: 8, Preview: "z8"},
+		{File: "inbox/a.md", Line: 1, Preview: "a1"},
+	}, 1)
+	if len(res) != 3 || res[0].File != "inbox/z.md" || res[0].Line != 8 || res[0].EndLine != 9 {
+		t.Fatalf("best-ranked file should stay first: %+v", res)
+	}
+	if res[1].Line != 1 || res[2].Line != 4 {
+		t.Fatalf("matches within a file should be in line order: %+v", res)
+	}
+}
@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"path/filepath"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -13,9 +15,34 @@ import (
 )
 
 type Message struct {
-	User string `json:"user"`
-	Text string `json:"text"`
-	Ts   string `json:"ts"`
+	User      string `json:"user"`
+	Text      string `json:"text"`
+	Ts        string `json:"ts"`
+	Permalink string `json:"permalink,omitempty"`
+	Edited    bool   `json:"edited,omitempty"`
+	Deleted   bool   `json:"deleted,omitempty"`
+	depth     int
+}
+
+const (
+	StyleDetailed = "detailed"
+	StyleCompact  = "compact"
+	StyleQuoted   = "quoted"
+)
+
+type CaptureOptions struct {
+	Format         string
+	Style          string
+	Anonymize      bool
+	Redact         bool
+	KeepMap        bool
+	ExcludeParent  bool
+	TeamDomain     string
+	Channel        string
+	Threaded       bool
+	RedactRegexps  []*regexp.Regexp
+	TimeFormat     string
+	IncludeDeleted bool
 }
 
 type CaptureResult struct {
@@ -27,9 +54,26 @@ type CaptureResult struct {
 var (
 	headerRe   = regexp.MustCompile(`^\s*(.+?)\s*\[(.+?)\]\s*$`)
 	tsPrefixRe = regexp.MustCompile(`^\s*\[(.+?)\]\s*(.*)$`)
+	emailRe    = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+	urlRe      = regexp.MustCompile(`(?i)\b(?:https?|ftp)://[^\s<>]+|\bwww\.[^\s<>]+`)
+	slackTsRe  = regexp.MustCompile(`^\d+\.\d+$`)
+	domainRe   = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+	editedRe   = regexp.MustCompile(`[ \t]*\(edited\)\z`)
+)
+
+const (
+	deletedTombstone = "This message was deleted."
+	editedMarker     = "(edited)"
+	deletedMarker    = "(deleted)"
+	redactedText     = "[REDACTED]"
+)
+
+const (
+	TimeFormatLocal = "local"
+	TimeFormatUTC   = "utc"
 )
 
-func Capture(ctx context.Context, root, transcript, format string) (CaptureResult, error) {
+func Capture(ctx context.Context, root, transcript string, opts CaptureOptions) (CaptureResult, error) {
 	if err := ctx.Err(); err != nil {
 		return CaptureResult{}, err
 	}
@@ -39,7 +83,56 @@ func Capture(ctx context.Context, root, transcript, format string) (CaptureResul
 	}
 
 	msgs := ParseTranscript(transcript)
-	text := renderMessages(msgs, format)
+	if opts.ExcludeParent && len(msgs) > 0 {
+		msgs = msgs[1:]
+		if len(msgs) == 0 {
+			return CaptureResult{}, errors.New("transcript has no replies")
+		}
+	}
+	edited, deleted := 0, 0
+	kept := msgs[:0]
+	for _, m := range msgs {
+		if m.Edited {
+			edited++
+		}
+		if m.Deleted {
+			deleted++
+			if !opts.IncludeDeleted {
+				continue
+			}
+		}
+		kept = append(kept, m)
+	}
+	msgs = kept
+	if len(msgs) == 0 {
+		return CaptureResult{}, errors.New("transcript has no messages left after skipping deleted ones")
+	}
+	linked := 0
+	for i := range msgs {
+		msgs[i].Permalink = Permalink(opts.TeamDomain, opts.Channel, msgs[i].Ts)
+		if msgs[i].Permalink != "" {
+			linked++
+		}
+	}
+	if opts.TimeFormat != "" {
+		formatTimestamps(msgs, opts.TimeFormat)
+	}
+	var userMap map[string]string
+	if opts.Anonymize {
+		msgs, userMap = anonymize(msgs)
+	}
+	if opts.Redact {
+		msgs = redactContacts(msgs)
+	}
+	redactions := 0
+	if len(opts.RedactRegexps) > 0 {
+		msgs, redactions = redactPatterns(msgs, opts.RedactRegexps)
+	}
+	threaded := 0
+	if opts.Threaded {
+		msgs, threaded = threadMessages(msgs)
+	}
+	text := renderMessages(markEdits(msgs), opts.Format, opts.Style)
 	filename := fmt.Sprintf("%s_%s.md", safeName(firstAuthor(msgs)), time.Now().Format("20060102T150405"))
 	saveAbs := filepath.Join(root, "slack", filename)
 	if err := rootio.AtomicWriteFile(saveAbs, []byte(text), 0o644); err != nil {
@@ -49,16 +142,112 @@ func Capture(ctx context.Context, root, transcript, format string) (CaptureResul
 	if err != nil {
 		rel = filepath.ToSlash(saveAbs)
 	}
+	meta := map[string]any{
+		"source":          "pasted_transcript",
+		"message_count":   len(msgs),
+		"parent_included": !opts.ExcludeParent,
+	}
+	if linked > 0 {
+		meta["permalinks"] = linked
+	}
+	if opts.Threaded {
+		meta["threaded_replies"] = threaded
+	}
+	if len(opts.RedactRegexps) > 0 {
+		meta["redactions"] = redactions
+	}
+	if edited > 0 {
+		meta["edited"] = edited
+	}
+	if deleted > 0 {
+		key := "deleted_skipped"
+		if opts.IncludeDeleted {
+			key = "deleted"
+		}
+		meta[key] = deleted
+	}
+	if opts.Anonymize {
+		meta["anonymized"] = true
+		if opts.KeepMap {
+			meta["user_map"] = userMap
+		}
+	}
 	return CaptureResult{
 		SavedPath: rel,
 		Text:      text,
-		Meta: map[string]any{
-			"source":        "pasted_transcript",
-			"message_count": len(msgs),
-		},
+		Meta:      meta,
 	}, nil
 }
 
+func Permalink(teamDomain, channel, ts string) string {
+	teamDomain = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(teamDomain), ".slack.com"))
+	channel = strings.TrimSpace(channel)
+	if !domainRe.MatchString(teamDomain) || channel == "" || strings.ContainsAny(channel, "/?# ") || !slackTsRe.MatchString(ts) {
+		return ""
+	}
+	return fmt.Sprintf("https://%s.slack.com/archives/%s/p%s", teamDomain, channel, strings.Replace(ts, ".", "", 1))
+}
+
+func ValidTimeFormat(format string) bool {
+	switch format {
+	case "", TimeFormatLocal, TimeFormatUTC:
+		return true
+	}
+	return time.Unix(0, 0).UTC().Format(format) != format
+}
+
+func formatTimestamps(msgs []Message, format string) {
+	layout, loc := format, time.Local
+	switch format {
+	case TimeFormatLocal:
+		layout = time.RFC3339
+	case TimeFormatUTC:
+		layout, loc = time.RFC3339, time.UTC
+	}
+	for i := range msgs {
+		if t, ok := parseTs(msgs[i].Ts); ok {
+			msgs[i].Ts = t.In(loc).Format(layout)
+		}
+	}
+}
+
+func parseTs(ts string) (time.Time, bool) {
+	if slackTsRe.MatchString(ts) {
+		sec, frac, _ := strings.Cut(ts, ".")
+		s, err := strconv.ParseInt(sec, 10, 64)
+		if err != nil {
+			return time.Time{}, false
+		}
+		micros, _ := strconv.ParseInt((frac + "000000")[:6], 10, 64)
+		return time.Unix(s, micros*int64(time.Microsecond)), true
+	}
+	if t, err := time.Parse(time.RFC3339, ts); err == nil {
+		return t, true
+	}
+	return time.Time{}, false
+}
+
+func markEdits(msgs []Message) []Message {
+	out := make([]Message, len(msgs))
+	for i, m := range msgs {
+		switch {
+		case m.Deleted:
+			m.Text = deletedMarker
+		case m.Edited:
+			m.Text = strings.TrimSpace(m.Text) + " " + editedMarker
+		}
+		out[i] = m
+	}
+	return out
+}
+
+func tsLabel(m Message) string {
+	if m.Permalink == "" {
+		return "`" + m.Ts + "`"
+	}
+	return fmt.Sprintf("[`%s`](%s)", m.Ts, m.Permalink)
+}
+
 func ParseTranscript(transcript string) []Message {
 	lines := strings.Split(strings.ReplaceAll(transcript, "\r\n", "\n"), "\n")
 	out := make([]Message, 0, 16)
@@ -69,6 +258,13 @@ func ParseTranscript(transcript string) []Message {
 			return
 		}
 		cur.Text = strings.TrimSpace(cur.Text)
+		if editedRe.MatchString(cur.Text) {
+			cur.Edited = true
+			cur.Text = strings.TrimSpace(editedRe.ReplaceAllString(cur.Text, ""))
+		}
+		if cur.Text == deletedTombstone {
+			cur.Deleted = true
+		}
 		if cur.Text != "" {
 			out = append(out, *cur)
 		}
@@ -115,7 +311,7 @@ func ParseTranscript(transcript string) []Message {
 	return out
 }
 
-func renderMessages(msgs []Message, format string) string {
+func renderMessages(msgs []Message, format, style string) string {
 	capturedAt := time.Now().Format(time.RFC3339)
 	if format == "text" {
 		var sb strings.Builder
@@ -125,16 +321,211 @@ func renderMessages(msgs []Message, format string) string {
 		}
 		return sb.String()
 	}
+	render, ok := markdownRenderers[style]
+	if !ok {
+		render = renderDetailed
+	}
 	var sb strings.Builder
 	sb.WriteString(fmt.Sprintf("**Imported conversation** source=slack pasted_text captured_at=%s\n\n", capturedAt))
+	render(&sb, msgs)
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+var markdownRenderers = map[string]func(*strings.Builder, []Message){
+	StyleDetailed: renderDetailed,
+	StyleCompact:  renderCompact,
+	StyleQuoted:   renderQuoted,
+}
+
+func ValidStyle(style string) bool {
+	if style == "" {
+		return true
+	}
+	_, ok := markdownRenderers[style]
+	return ok
+}
+
+func renderDetailed(sb *strings.Builder, msgs []Message) {
 	for _, m := range msgs {
-		sb.WriteString(fmt.Sprintf("- `%s` **%s**:\n", m.Ts, m.User))
+		indent := strings.Repeat("  ", m.depth)
+		sb.WriteString(fmt.Sprintf("%s- %s **%s**:\n", indent, tsLabel(m), m.User))
 		for _, line := range strings.Split(strings.TrimSpace(m.Text), "\n") {
-			sb.WriteString("  " + line + "\n")
+			sb.WriteString(indent + "  " + line + "\n")
 		}
 		sb.WriteString("\n")
 	}
-	return strings.TrimRight(sb.String(), "\n")
+}
+
+func threadMessages(msgs []Message) ([]Message, int) {
+	parent := make([]int, len(msgs))
+	children := make([][]int, len(msgs))
+	var roots []int
+	threaded := 0
+	for i := range msgs {
+		parent[i] = -1
+		quote, rest := splitLeadingQuote(msgs[i].Text)
+		if quote != "" && rest != "" {
+			for j := i - 1; j >= 0; j-- {
+				if strings.Contains(collapseSpace(msgs[j].Text), quote) {
+					parent[i] = j
+					break
+				}
+			}
+		}
+		if parent[i] < 0 {
+			roots = append(roots, i)
+			continue
+		}
+		msgs[i].Text = rest
+		children[parent[i]] = append(children[parent[i]], i)
+		threaded++
+	}
+	out := make([]Message, 0, len(msgs))
+	var walk func(i, depth int)
+	walk = func(i, depth int) {
+		m := msgs[i]
+		m.depth = depth
+		out = append(out, m)
+		for _, c := range children[i] {
+			walk(c, depth+1)
+		}
+	}
+	for _, r := range roots {
+		walk(r, 0)
+	}
+	return out, threaded
+}
+
+func splitLeadingQuote(text string) (string, string) {
+	lines := strings.Split(text, "\n")
+	var quoted []string
+	i := 0
+	for ; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if !strings.HasPrefix(line, ">") {
+			break
+		}
+		quoted = append(quoted, strings.TrimSpace(strings.TrimPrefix(line, ">")))
+	}
+	return collapseSpace(strings.Join(quoted, " ")), strings.TrimSpace(strings.Join(lines[i:], "\n"))
+}
+
+func collapseSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func renderCompact(sb *strings.Builder, msgs []Message) {
+	for i, m := range msgs {
+		if i == 0 || msgs[i-1].User != m.User {
+			if i > 0 {
+				sb.WriteString("\n")
+			}
+			sb.WriteString(fmt.Sprintf("**%s** %s\n", m.User, tsLabel(m)))
+		}
+		sb.WriteString(normalizeFences(strings.TrimSpace(m.Text)) + "\n")
+	}
+}
+
+func renderQuoted(sb *strings.Builder, msgs []Message) {
+	for i, m := range msgs {
+		if i == 0 || msgs[i-1].User != m.User {
+			if i > 0 {
+				sb.WriteString("\n")
+			}
+			sb.WriteString(fmt.Sprintf("**%s** %s\n", m.User, tsLabel(m)))
+		} else {
+			sb.WriteString(">\n")
+		}
+		for _, line := range strings.Split(normalizeFences(strings.TrimSpace(m.Text)), "\n") {
+			sb.WriteString(strings.TrimRight("> "+line, " ") + "\n")
+		}
+	}
+}
+
+func normalizeFences(text string) string {
+	lines := strings.Split(text, "\n")
+	out := make([]string, 0, len(lines))
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if len(trimmed) > 6 && strings.HasPrefix(trimmed, "```") && strings.HasSuffix(trimmed, "```") {
+			out = append(out, "```", strings.TrimSpace(trimmed[3:len(trimmed)-3]), "```")
+			continue
+		}
+		out = append(out, line)
+	}
+	return strings.Join(out, "\n")
+}
+
+func anonymize(msgs []Message) ([]Message, map[string]string) {
+	pseudonyms := map[string]string{}
+	out := make([]Message, len(msgs))
+	for i, m := range msgs {
+		if m.User != "unknown" {
+			if _, ok := pseudonyms[m.User]; !ok {
+				pseudonyms[m.User] = fmt.Sprintf("User%d", len(pseudonyms)+1)
+			}
+			m.User = pseudonyms[m.User]
+		}
+		out[i] = m
+	}
+	names := make([]string, 0, len(pseudonyms))
+	for real := range pseudonyms {
+		names = append(names, real)
+	}
+	sort.Slice(names, func(i, j int) bool { return len(names[i]) > len(names[j]) })
+	for i := range out {
+		for _, real := range names {
+			out[i].Text = strings.ReplaceAll(out[i].Text, "@"+real, "@"+pseudonyms[real])
+		}
+	}
+	userMap := make(map[string]string, len(pseudonyms))
+	for real, alias := range pseudonyms {
+		userMap[alias] = real
+	}
+	return out, userMap
+}
+
+func redactContacts(msgs []Message) []Message {
+	out := make([]Message, len(msgs))
+	for i, m := range msgs {
+		m.Text = urlRe.ReplaceAllString(m.Text, "[url]")
+		m.Text = emailRe.ReplaceAllString(m.Text, "[email]")
+		out[i] = m
+	}
+	return out
+}
+
+func CompileRedactPatterns(patterns []string) ([]*regexp.Regexp, error) {
+	out := make([]*regexp.Regexp, 0, len(patterns))
+	for _, p := range patterns {
+		if p == "" {
+			return nil, errors.New("empty redact pattern")
+		}
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("redact pattern %q: %w", p, err)
+		}
+		if re.MatchString("") {
+			return nil, fmt.Errorf("redact pattern %q matches the empty string", p)
+		}
+		out = append(out, re)
+	}
+	return out, nil
+}
+
+func redactPatterns(msgs []Message, patterns []*regexp.Regexp) ([]Message, int) {
+	out := make([]Message, len(msgs))
+	count := 0
+	for i, m := range msgs {
+		for _, re := range patterns {
+			m.Text = re.ReplaceAllStringFunc(m.Text, func(string) string {
+				count++
+				return redactedText
+			})
+		}
+		out[i] = m
+	}
+	return out, count
 }
 
 func firstAuthor(msgs []Message) string {
@@ -1,6 +1,10 @@
 package slackcap
 
-import "testing"
+import (
+	"context"
+	"strings"
+	"testing"
+)
 
 func TestParseTranscriptContinuationSameTimestamp(t *testing.T) {
 	in := `sean  [10:48 AM]
@@ -35,3 +39,198 @@ func TestParseTranscriptTimestampPrefixStartsNewMessage(t *testing.T) {
 		t.Fatalf("text=%q", msgs[1].Text)
 	}
 }
+
+func TestAnonymizeAssignsStablePseudonyms(t *testing.T) {
+	msgs := []Message{
+		{User: "sean", Text: "hi @Sarine"},
+		{User: "Sarine", Text: "hey"},
+		{User: "sean", Text: "mail me at sean@example.com or see https://example.com/x"},
+	}
+	out, userMap := anonymize(msgs)
+	if out[0].User != "User1" || out[1].User != "User2" || out[2].User != "User1" {
+		t.Fatalf("unexpected users: %+v", out)
+	}
+	if out[0].Text != "hi @User2" {
+		t.Fatalf("mention not anonymized: %q", out[0].Text)
+	}
+	if userMap["User1"] != "sean" || userMap["User2"] != "Sarine" {
+		t.Fatalf("unexpected map: %v", userMap)
+	}
+	redacted := redactContacts(out)
+	if redacted[2].Text != "mail me at [email] or see [url]" {
+		t.Fatalf("text=%q", redacted[2].Text)
+	}
+}
+
+func TestRenderCompactCollapsesSameUserAndFencesCode(t *testing.T) {
+	msgs := []Message{
+		{User: "sean", Ts: "10:48 AM", Text: "hello"},
+		{User: "sean", Ts: "10:49 AM", Text: "```go run .```"},
+		{User: "Sarine", Ts: "10:50 AM", Text: "ok"},
+	}
+	out := renderMessages(msgs, "markdown", StyleCompact)
+	want := "**sean** `10:48 AM`\nhello\n```\ngo run .\n```\n\n**Sarine** `10:50 AM`\nok"
+	if !strings.HasSuffix(out, want) {
+		t.Fatalf("unexpected output:\n%s", out)
+	}
+}
+
+func TestRenderQuotedWrapsLinesInBlockquote(t *testing.T) {
+	msgs := []Message{{User: "sean", Ts: "10:48 AM", Text: "line one\n\nline two"}}
+	out := renderMessages(msgs, "markdown", StyleQuoted)
+	want := "**sean** `10:48 AM`\n> line one\n>\n> line two"
+	if !strings.HasSuffix(out, want) {
+		t.Fatalf("unexpected output:\n%s", out)
+	}
+}
+
+func TestCaptureExcludeParentDropsFirstMessage(t *testing.T) {
+	root := t.TempDir()
+	in := "sean  [10:48 AM]\nroot question\nSarine  [10:49 AM]\nreply"
+	res, err := Capture(context.Background(), root, in, CaptureOptions{ExcludeParent: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(res.Text, "root question") || !strings.Contains(res.Text, "reply") {
+		t.Fatalf("unexpected text:\n%s", res.Text)
+	}
+	if res.Meta["message_count"] != 1 {
+		t.Fatalf("meta=%v", res.Meta)
+	}
+	if _, err := Capture(context.Background(), root, "sean  [10:48 AM]\nalone", CaptureOptions{ExcludeParent: true}); err == nil {
+		t.Fatal("expected error when no replies remain")
+	}
+}
+
+func TestCapturePermalinksRequireDomainAndSlackTs(t *testing.T) {
+	if got := Permalink("acme", "C0123", "1712345678.123456"); got != "https://acme.slack.com/archives/C0123/p1712345678123456" {
+		t.Fatalf("permalink=%q", got)
+	}
+	if got := Permalink("", "C0123", "1712345678.123456"); got != "" {
+		t.Fatalf("expected no permalink without domain, got %q", got)
+	}
+	root := t.TempDir()
+	in := "sean  [1712345678.123456]\nhello\nSarine  [10:49 AM]\nreply"
+	res, err := Capture(context.Background(), root, in, CaptureOptions{TeamDomain: "acme.slack.com", Channel: "C0123"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(res.Text, "- [`1712345678.123456`](https://acme.slack.com/archives/C0123/p1712345678123456) **sean**:") {
+		t.Fatalf("missing permalink:\n%s", res.Text)
+	}
+	if !strings.Contains(res.Text, "- `10:49 AM` **Sarine**:") || res.Meta["permalinks"] != 1 {
+		t.Fatalf("unexpected output:\n%s\nmeta=%v", res.Text, res.Meta)
+	}
+}
+
+func TestCaptureRedactPatternsCountsMatches(t *testing.T) {
+	if _, err := CompileRedactPatterns([]string{"("}); err == nil {
+		t.Fatal("expected invalid pattern error")
+	}
+	for _, p := range []string{`x*`, `^`, `(?:secret)?`} {
+		if _, err := CompileRedactPatterns([]string{p}); err == nil {
+			t.Fatalf("expected %q to be rejected for matching the empty string", p)
+		}
+	}
+	patterns, err := CompileRedactPatterns([]string{`\b\d{4}(?:[ -]?\d{4}){3}\b`, `xoxb-[A-Za-z0-9-]+`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := t.TempDir()
+	in := "sean  [10:48 AM]\ncard 4111 1111 1111 1111 and 4242424242424242\nSarine  [10:49 AM]\ntoken xoxb-123-abc"
+	res, err := Capture(context.Background(), root, in, CaptureOptions{RedactRegexps: patterns})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(res.Text, "4111") || strings.Contains(res.Text, "xoxb") {
+		t.Fatalf("sensitive text survived:\n%s", res.Text)
+	}
+	if strings.Count(res.Text, "[REDACTED]") != 3 || res.Meta["redactions"] != 3 {
+		t.Fatalf("unexpected output:\n%s\nmeta=%v", res.Text, res.Meta)
+	}
+}
+
+func TestThreadMessagesIndentsQuotedReplies(t *testing.T) {
+	msgs := []Message{
+		{User: "sean", Ts: "10:48 AM", Text: "should we ship friday?"},
+		{User: "ana", Ts: "10:49 AM", Text: "unrelated: lunch?"},
+		{User: "Sarine", Ts: "10:50 AM", Text: "> should we ship\n> friday?\nyes, after QA"},
+		{User: "sean", Ts: "10:51 AM", Text: "> yes, after QA\nok"},
+		{User: "ana", Ts: "10:52 AM", Text: "> never said this\nhm"},
+	}
+	threaded, n := threadMessages(msgs)
+	if n != 2 {
+		t.Fatalf("threaded=%d", n)
+	}
+	out := renderMessages(threaded, "markdown", StyleDetailed)
+	want := "- `10:48 AM` **sean**:\n  should we ship friday?\n\n" +
+		"  - `10:50 AM` **Sarine**:\n    yes, after QA\n\n" +
+		"    - `10:51 AM` **sean**:\n      ok\n\n" +
+		"- `10:49 AM` **ana**:\n  unrelated: lunch?\n\n" +
+		"- `10:52 AM` **ana**:\n  > never said this\n  hm"
+	if !strings.HasSuffix(out, want) {
+		t.Fatalf("unexpected output:\n%s", out)
+	}
+}
+
+func TestCaptureTimeFormatAppliesToBothRenderers(t *testing.T) {
+	for _, f := range []string{"", TimeFormatLocal, TimeFormatUTC, "2006-01-02 15:04"} {
+		if !ValidTimeFormat(f) {
+			t.Fatalf("expected %q to be valid", f)
+		}
+	}
+	if ValidTimeFormat("yesterday") {
+		t.Fatal("expected layout without time fields to be rejected")
+	}
+	root := t.TempDir()
+	in := "sean  [1712345678.123456]\nhello\nSarine  [10:49 AM]\nreply"
+	for _, format := range []string{"markdown", "text"} {
+		res, err := Capture(context.Background(), root, in, CaptureOptions{Format: format, TimeFormat: TimeFormatUTC})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(res.Text, "2024-04-05T19:34:38Z") || strings.Contains(res.Text, "1712345678") {
+			t.Fatalf("%s: epoch ts not formatted:\n%s", format, res.Text)
+		}
+		if !strings.Contains(res.Text, "10:49 AM") {
+			t.Fatalf("%s: clock-only ts should pass through:\n%s", format, res.Text)
+		}
+	}
+	res, err := Capture(context.Background(), root, in, CaptureOptions{TeamDomain: "acme", Channel: "C1", TimeFormat: TimeFormatUTC})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(res.Text, "[`2024-04-05T19:34:38Z`](https://acme.slack.com/archives/C1/p1712345678123456)") {
+		t.Fatalf("permalink should still use the raw ts:\n%s", res.Text)
+	}
+}
+
+func TestCaptureMarksEditedAndSkipsDeleted(t *testing.T) {
+	in := "sean  [10:48 AM]\nship it friday (edited)\nSarine  [10:49 AM]\nThis message was deleted.\nAlex  [10:50 AM]\nok"
+	msgs := ParseTranscript(in)
+	if len(msgs) != 3 || !msgs[0].Edited || msgs[0].Text != "ship it friday" || !msgs[1].Deleted {
+		t.Fatalf("unexpected parse: %+v", msgs)
+	}
+	root := t.TempDir()
+	res, err := Capture(context.Background(), root, in, CaptureOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(res.Text, "ship it friday (edited)") || strings.Contains(res.Text, "Sarine") {
+		t.Fatalf("unexpected text:\n%s", res.Text)
+	}
+	if res.Meta["edited"] != 1 || res.Meta["deleted_skipped"] != 1 || res.Meta["message_count"] != 2 {
+		t.Fatalf("meta=%v", res.Meta)
+	}
+	msgs = ParseTranscript("sean  [10:48 AM]\nthe old copy said (edited)\nnew line (edited)")
+	if len(msgs) != 1 || !msgs[0].Edited || msgs[0].Text != "the old copy said (edited)\nnew line" {
+		t.Fatalf("only the trailing marker should be stripped: %+v", msgs)
+	}
+	res, err = Capture(context.Background(), root, in, CaptureOptions{Format: "text", IncludeDeleted: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(res.Text, "[10:49 AM] Sarine: (deleted)") || res.Meta["deleted"] != 1 {
+		t.Fatalf("unexpected output:\n%s\nmeta=%v", res.Text, res.Meta)
+	}
+}
@@ -0,0 +1,88 @@
+package snapshot
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"margin/internal/rootio"
+)
+
+type fileState struct {
+	mtime     time.Time
+	snappedAt time.Time
+}
+
+type Snapshotter struct {
+	Root     string
+	Interval time.Duration
+	last     map[string]fileState
+}
+
+func New(root string, interval time.Duration) *Snapshotter {
+	return &Snapshotter{Root: root, Interval: interval, last: map[string]fileState{}}
+}
+
+func (s *Snapshotter) currentFiles() ([]string, error) {
+	return rootio.ListFilesRecursive([]string{filepath.Join(s.Root, "scratch", "current")}, rootio.WalkOptions{})
+}
+
+func (s *Snapshotter) Baseline(now time.Time) error {
+	files, err := s.currentFiles()
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		if st, err := os.Stat(f); err == nil {
+			s.last[f] = fileState{mtime: st.ModTime(), snappedAt: now}
+		}
+	}
+	return nil
+}
+
+func (s *Snapshotter) Tick(ctx context.Context, now time.Time) ([]string, error) {
+	files, err := s.currentFiles()
+	if err != nil {
+		return nil, err
+	}
+	created := []string{}
+	for _, f := range files {
+		if err := ctx.Err(); err != nil {
+			return created, err
+		}
+		st, err := os.Stat(f)
+		if err != nil {
+			continue
+		}
+		prev, seen := s.last[f]
+		if seen && (st.ModTime().Equal(prev.mtime) || now.Sub(prev.snappedAt) < s.Interval) {
+			continue
+		}
+		data, err := os.ReadFile(f)
+		if err != nil {
+			continue
+		}
+		dest := HistoryPath(s.Root, f, now)
+		if err := rootio.AtomicWriteFile(dest, data, 0o644); err != nil {
+			return created, fmt.Errorf("snapshot %s: %w", f, err)
+		}
+		s.last[f] = fileState{mtime: st.ModTime(), snappedAt: now}
+		rel, err := rootio.RelUnderRoot(s.Root, dest)
+		if err != nil {
+			rel = filepath.ToSlash(dest)
+		}
+		created = append(created, rel)
+	}
+	return created, nil
+}
+
+func HistoryPath(root, currentFile string, now time.Time) string {
+	ext := filepath.Ext(currentFile)
+	id := strings.TrimSuffix(filepath.Base(currentFile), ext)
+	stamp := now.Format("20060102T150405") + fmt.Sprintf("%06d", now.Nanosecond()/1000)
+	dir := filepath.Join(root, "scratch", "history", now.Format("2006"), now.Format("2006-01-02"))
+	return filepath.Join(dir, stamp+"_"+id+ext)
+}
@@ -0,0 +1,55 @@
+package snapshot
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTickSnapshotsChangedFilesPerInterval(t *testing.T) {
+	root := t.TempDir()
+	current := filepath.Join(root, "scratch", "current")
+	if err := os.MkdirAll(current, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	note := filepath.Join(current, "abc123.md")
+	if err := os.WriteFile(note, []byte("v1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2026, 3, 1, 9, 0, 0, 0, time.Local)
+	s := New(root, 10*time.Minute)
+	if err := s.Baseline(start); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := s.Tick(context.Background(), start.Add(time.Minute)); err != nil || len(got) != 0 {
+		t.Fatalf("unchanged file snapshotted: %v %v", got, err)
+	}
+
+	if err := os.WriteFile(note, []byte("v2\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(note, start.Add(2*time.Minute), start.Add(2*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := s.Tick(context.Background(), start.Add(5*time.Minute)); len(got) != 0 {
+		t.Fatalf("snapshot taken before interval elapsed: %v", got)
+	}
+	at := start.Add(11 * time.Minute)
+	got, err := s.Tick(context.Background(), at)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "scratch/history/2026/2026-03-01/20260301T091100000000_abc123.md"
+	if len(got) != 1 || got[0] != want {
+		t.Fatalf("got %v want %s", got, want)
+	}
+	data, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(want)))
+	if err != nil || string(data) != "v2\n" {
+		t.Fatalf("snapshot content=%q err=%v", data, err)
+	}
+	if got, _ := s.Tick(context.Background(), at.Add(20*time.Minute)); len(got) != 0 {
+		t.Fatalf("unchanged file snapshotted again: %v", got)
+	}
+}
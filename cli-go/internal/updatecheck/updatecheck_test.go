@@ -0,0 +1,40 @@
+package updatecheck
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCheckComparesAgainstLatestTag(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"tag_name":"v1.4.0"}`))
+	}))
+	defer srv.Close()
+
+	res := Check(context.Background(), srv.URL, "1.3.9")
+	if res.Latest != "v1.4.0" || !res.UpdateAvailable || res.Error != "" {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	res = Check(context.Background(), srv.URL, "v1.4.0")
+	if res.UpdateAvailable {
+		t.Fatalf("same version should not need an update: %+v", res)
+	}
+	res = Check(context.Background(), srv.URL, "dev")
+	if res.UpdateAvailable {
+		t.Fatalf("unparseable current version should not report an update: %+v", res)
+	}
+}
+
+func TestCheckDegradesOnFailure(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "rate limited", http.StatusForbidden)
+	}))
+	defer srv.Close()
+
+	res := Check(context.Background(), srv.URL, "1.0.0")
+	if res.Current != "1.0.0" || res.Latest != "" || res.UpdateAvailable || res.Error == "" {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+}